@@ -0,0 +1,414 @@
+// Copyright © 2019 gcp-config Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cbif
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/storage/v1"
+)
+
+var (
+	errCacheMiss = errors.New("cache miss")
+)
+
+// cacheStore saves and restores cache archives by key.
+type cacheStore interface {
+	// Get writes the archive for key to w, or returns errCacheMiss.
+	Get(ctx context.Context, key string, w io.Writer) error
+	// Put saves the archive read from r for key.
+	Put(ctx context.Context, key string, r io.Reader) error
+}
+
+// localStore saves cache archives in a local directory.
+type localStore struct {
+	dir string
+}
+
+func (l *localStore) file(key string) string {
+	return filepath.Join(l.dir, key+".tar.gz")
+}
+
+func (l *localStore) Get(ctx context.Context, key string, w io.Writer) error {
+	f, err := os.Open(l.file(key))
+	if os.IsNotExist(err) {
+		return errCacheMiss
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func (l *localStore) Put(ctx context.Context, key string, r io.Reader) error {
+	if err := os.MkdirAll(l.dir, 0777); err != nil {
+		return err
+	}
+	// Write to a temporary file first so readers never see a partial archive.
+	f, err := os.CreateTemp(l.dir, key+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), l.file(key))
+}
+
+// gcsStore saves cache archives in a GCS bucket.
+type gcsStore struct {
+	service *storage.Service
+	bucket  string
+	prefix  string
+}
+
+func (g *gcsStore) object(key string) string {
+	return path.Join(g.prefix, key+".tar.gz")
+}
+
+func (g *gcsStore) Get(ctx context.Context, key string, w io.Writer) error {
+	resp, err := g.service.Objects.Get(g.bucket, g.object(key)).Context(ctx).Download()
+	if e, ok := err.(*googleapi.Error); ok && e.Code == http.StatusNotFound {
+		return errCacheMiss
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+func (g *gcsStore) Put(ctx context.Context, key string, r io.Reader) error {
+	obj := &storage.Object{Name: g.object(key)}
+	_, err := g.service.Objects.Insert(g.bucket, obj).Media(r).Context(ctx).Do()
+	return err
+}
+
+// newCacheStore returns a GCS store for "gs://bucket/prefix" locations, and a
+// local directory store otherwise.
+func newCacheStore(ctx context.Context, location string) (cacheStore, error) {
+	if !strings.HasPrefix(location, "gs://") {
+		return &localStore{dir: location}, nil
+	}
+	fields := strings.SplitN(strings.TrimPrefix(location, "gs://"), "/", 2)
+	g := &gcsStore{bucket: fields[0]}
+	if len(fields) == 2 {
+		g.prefix = fields[1]
+	}
+	var err error
+	g.service, err = storage.NewService(ctx)
+	return g, err
+}
+
+// cacheKeyWithHash returns the key with the hash of the contents of the given
+// files appended, e.g. to key on the contents of go.sum.
+func cacheKeyWithHash(key string, files []string) (string, error) {
+	if len(files) == 0 {
+		return key, nil
+	}
+	h := sha256.New()
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("%s-%x", key, h.Sum(nil)[:8]), nil
+}
+
+// archiveRoot returns p relative to dir, unless p is absolute.
+func archiveRoot(dir, p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(dir, p)
+}
+
+// writeArchive writes a gzipped tar archive of the given paths to w. Relative
+// paths are relative to dir and are saved with relative names.
+func writeArchive(w io.Writer, dir string, paths []string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, p := range paths {
+		err := filepath.Walk(archiveRoot(dir, p), func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			link := ""
+			if info.Mode()&os.ModeSymlink != 0 {
+				if link, err = os.Readlink(file); err != nil {
+					return err
+				}
+			}
+			hdr, err := tar.FileInfoHeader(info, link)
+			if err != nil {
+				return err
+			}
+			name := file
+			if !filepath.IsAbs(p) {
+				if name, err = filepath.Rel(dir, file); err != nil {
+					return err
+				}
+			}
+			hdr.Name = filepath.ToSlash(name)
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+			f, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = io.Copy(tw, f)
+			return err
+		})
+		if os.IsNotExist(err) {
+			log.Printf("Cache path does not exist, skipping: %q", p)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// allowedPath returns the one of the given paths that name is or is within.
+func allowedPath(paths []string, name string) (string, bool) {
+	for _, p := range paths {
+		if p = filepath.Clean(p); isWithin(p, name) {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// checkNoSymlinks returns an error if any existing directory from root down to
+// the parent of target is a symlink, so that an archive cannot first create a
+// symlink and then write through it to outside the cache paths.
+func checkNoSymlinks(root, target string) error {
+	if target == root {
+		return nil
+	}
+	rel, err := filepath.Rel(root, filepath.Dir(target))
+	if err != nil {
+		return err
+	}
+	p := root
+	for _, elem := range append([]string{"."}, strings.Split(rel, string(filepath.Separator))...) {
+		p = filepath.Join(p, elem)
+		info, err := os.Lstat(p)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("archive path is through a symlink: %q", p)
+		}
+	}
+	return nil
+}
+
+// extractArchive extracts a gzipped tar archive read from r. Archive entries
+// keep the paths they were saved with and must be within the given paths.
+// Relative paths are relative to dir.
+func extractArchive(r io.Reader, dir string, paths []string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.Clean(filepath.FromSlash(hdr.Name))
+		p, ok := allowedPath(paths, name)
+		if !ok {
+			return fmt.Errorf("archive path is not within cache paths: %q", hdr.Name)
+		}
+		target := archiveRoot(dir, name)
+		if err := checkNoSymlinks(archiveRoot(dir, p), target); err != nil {
+			return err
+		}
+		mode := os.FileMode(hdr.Mode).Perm()
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			// Keep directories writable, e.g. the read-only Go module cache.
+			err = os.MkdirAll(target, mode|0700)
+		case tar.TypeSymlink:
+			os.Remove(target)
+			err = os.Symlink(hdr.Linkname, target)
+		case tar.TypeReg:
+			// Replace rather than write through an existing symlink.
+			if info, lerr := os.Lstat(target); lerr == nil && info.Mode()&os.ModeSymlink != 0 {
+				os.Remove(target)
+			}
+			err = extractFile(tr, target, mode)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func extractFile(r io.Reader, target string, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0777); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// restoreCache restores the archive for key to the given paths relative to
+// dir. The returned bool is true when the key was found in the store.
+func restoreCache(ctx context.Context, store cacheStore, key, dir string, paths []string) (bool, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(store.Get(ctx, key, pw))
+	}()
+	err := extractArchive(pr, dir, paths)
+	pr.Close()
+	if errors.Is(err, errCacheMiss) {
+		log.Printf("Cache miss: %q", key)
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	log.Printf("Cache restored: %q", key)
+	return true, nil
+}
+
+// saveCache saves an archive of the given paths relative to dir to the store
+// under key.
+func saveCache(ctx context.Context, store cacheStore, key, dir string, paths []string) error {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeArchive(pw, dir, paths))
+	}()
+	err := store.Put(ctx, key, pr)
+	pr.Close()
+	if err != nil {
+		return err
+	}
+	log.Printf("Cache saved: %q %v", key, paths)
+	return nil
+}
+
+// trySetupCache restores the cache when CACHE_KEY is assigned. The returned
+// function saves the cache after commands complete successfully, but only when
+// the key was not already found in the store. Cache errors are logged but
+// otherwise ignored since the cache is only an optimization.
+func (r *Runner) trySetupCache(ctx context.Context) (func(success bool), error) {
+	if !r.Assigned.Assigned("CACHE_KEY") {
+		return func(bool) {}, nil
+	}
+	if err := r.requireCacheOptions(); err != nil {
+		return nil, err
+	}
+	store, err := newCacheStore(ctx, r.CacheStore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cache store: %q: %w", r.CacheStore, err)
+	}
+	key, err := r.cacheKey()
+	if err != nil {
+		return nil, err
+	}
+
+	hit, err := restoreCache(ctx, store, key, r.dir, r.CachePaths)
+	if err != nil {
+		log.Printf("Failed to restore cache %q: %v", key, err)
+	}
+	return func(success bool) {
+		switch {
+		case !success:
+			log.Printf("Commands failed; not saving cache %q", key)
+		case hit:
+			log.Printf("Cache key unchanged; not saving cache %q", key)
+		default:
+			if err := saveCache(ctx, store, key, r.dir, r.CachePaths); err != nil {
+				log.Printf("Failed to save cache %q: %v", key, err)
+			}
+		}
+	}, nil
+}
+
+// cacheKey returns the CACHE_KEY including the hash of CACHE_KEY_FILES.
+func (r *Runner) cacheKey() (string, error) {
+	files := make([]string, 0, len(r.CacheKeyFiles))
+	for _, f := range r.CacheKeyFiles {
+		files = append(files, r.path(f))
+	}
+	key, err := cacheKeyWithHash(r.CacheKey, files)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash cache key files: %v: %w", r.CacheKeyFiles, err)
+	}
+	return key, nil
+}
+
+func (r *Runner) requireCacheOptions() error {
+	if r.CacheStore == "" {
+		return errors.New("CACHE_STORE is required with CACHE_KEY")
+	}
+	if len(r.CachePaths) == 0 {
+		return errors.New("CACHE_PATHS is required with CACHE_KEY")
+	}
+	return nil
+}
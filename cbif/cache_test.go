@@ -0,0 +1,154 @@
+package cbif
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/m-lab/go/rtx"
+)
+
+func Test_saveCache_restoreCache(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := newCacheStore(ctx, filepath.Join(dir, "store"))
+	rtx.Must(err, "failed to create local store")
+	src := filepath.Join(dir, "src")
+	writeFile(t, filepath.Join(src, "a.txt"), "a")
+	writeFile(t, filepath.Join(src, "sub/b.txt"), "b")
+	rtx.Must(os.Symlink("a.txt", filepath.Join(src, "link")), "failed to create symlink")
+
+	hit, err := restoreCache(ctx, store, "key", dir, []string{src})
+	if err != nil || hit {
+		t.Fatalf("restoreCache() empty store = %t, %v; want false, nil", hit, err)
+	}
+	rtx.Must(saveCache(ctx, store, "key", dir, []string{src, filepath.Join(dir, "does-not-exist")}), "failed to save cache")
+
+	// Remove the original files and restore them from the cache.
+	rtx.Must(os.RemoveAll(src), "failed to remove src")
+	hit, err = restoreCache(ctx, store, "key", dir, []string{src})
+	if err != nil || !hit {
+		t.Fatalf("restoreCache() = %t, %v; want true, nil", hit, err)
+	}
+	for name, want := range map[string]string{"a.txt": "a", "sub/b.txt": "b", "link": "a"} {
+		if got := readFile(t, filepath.Join(src, name)); got != want {
+			t.Errorf("restoreCache() %q = %q, want %q", name, got, want)
+		}
+	}
+
+	// Restoring to paths that do not include the archive contents fails.
+	_, err = restoreCache(ctx, store, "key", dir, []string{filepath.Join(dir, "other")})
+	if err == nil {
+		t.Errorf("restoreCache() to other paths should fail")
+	}
+}
+
+func Test_cacheKeyWithHash(t *testing.T) {
+	dir := t.TempDir()
+	sum := filepath.Join(dir, "go.sum")
+	writeFile(t, sum, "original")
+
+	k1, err := cacheKeyWithHash("go", []string{sum})
+	rtx.Must(err, "failed to hash key")
+	k2, err := cacheKeyWithHash("go", []string{sum})
+	rtx.Must(err, "failed to hash key")
+	if k1 != k2 {
+		t.Errorf("cacheKeyWithHash() not stable; %q != %q", k1, k2)
+	}
+	writeFile(t, sum, "modified")
+	k3, err := cacheKeyWithHash("go", []string{sum})
+	rtx.Must(err, "failed to hash key")
+	if k1 == k3 {
+		t.Errorf("cacheKeyWithHash() did not change with file contents; %q", k3)
+	}
+	if k, _ := cacheKeyWithHash("go", nil); k != "go" {
+		t.Errorf("cacheKeyWithHash() without files = %q, want %q", k, "go")
+	}
+	if _, err := cacheKeyWithHash("go", []string{filepath.Join(dir, "missing")}); err == nil {
+		t.Errorf("cacheKeyWithHash() with missing file should fail")
+	}
+}
+
+func Test_saveCache_restoreCache_relativePaths(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := newCacheStore(ctx, filepath.Join(dir, "store"))
+	rtx.Must(err, "failed to create local store")
+	src := filepath.Join(dir, "src")
+	writeFile(t, filepath.Join(src, "vendor/a.txt"), "a")
+
+	rtx.Must(saveCache(ctx, store, "key", src, []string{"vendor"}), "failed to save cache")
+
+	// Relative paths are restored relative to the new directory.
+	dst := filepath.Join(dir, "dst")
+	hit, err := restoreCache(ctx, store, "key", dst, []string{"vendor"})
+	if err != nil || !hit {
+		t.Fatalf("restoreCache() = %t, %v; want true, nil", hit, err)
+	}
+	if got := readFile(t, filepath.Join(dst, "vendor/a.txt")); got != "a" {
+		t.Errorf("restoreCache() vendor/a.txt = %q, want %q", got, "a")
+	}
+}
+
+func Test_extractArchive_symlinkEscape(t *testing.T) {
+	dir := t.TempDir()
+	outside := filepath.Join(dir, "outside")
+	rtx.Must(os.Mkdir(outside, 0755), "failed to create outside dir")
+
+	tests := []struct {
+		name    string
+		entries []*tar.Header
+	}{
+		{
+			name: "file-through-symlink",
+			entries: []*tar.Header{
+				{Name: "p/x", Typeflag: tar.TypeSymlink, Linkname: outside},
+				{Name: "p/x/passwd", Typeflag: tar.TypeReg, Mode: 0644, Size: 1},
+			},
+		},
+		{
+			name: "file-through-root-symlink",
+			entries: []*tar.Header{
+				{Name: "p", Typeflag: tar.TypeSymlink, Linkname: outside},
+				{Name: "p/passwd", Typeflag: tar.TypeReg, Mode: 0644, Size: 1},
+			},
+		},
+		{
+			name: "dir-through-symlink",
+			entries: []*tar.Header{
+				{Name: "p/x", Typeflag: tar.TypeSymlink, Linkname: outside},
+				{Name: "p/x/sub", Typeflag: tar.TypeDir, Mode: 0755},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			gz := gzip.NewWriter(buf)
+			tw := tar.NewWriter(gz)
+			for _, hdr := range tt.entries {
+				rtx.Must(tw.WriteHeader(hdr), "failed to write header")
+				if hdr.Size > 0 {
+					_, err := tw.Write([]byte("x"))
+					rtx.Must(err, "failed to write content")
+				}
+			}
+			rtx.Must(tw.Close(), "failed to close tar")
+			rtx.Must(gz.Close(), "failed to close gzip")
+
+			dst := filepath.Join(dir, tt.name)
+			rtx.Must(os.MkdirAll(filepath.Join(dst, "p"), 0755), "failed to create cache path")
+			err := extractArchive(buf, dst, []string{"p"})
+			if err == nil {
+				t.Errorf("extractArchive() succeeded; want error")
+			}
+			if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+				t.Errorf("extractArchive() wrote outside the cache paths: %v", entries)
+			}
+		})
+	}
+}
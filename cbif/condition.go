@@ -0,0 +1,90 @@
+// Copyright © 2019 gcp-config Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cbif
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// StringList is a flag.Value that appends each value to the list. Unlike
+// flagx.StringArray, values are not split on commas, so they may contain
+// comma separated values or regular expressions.
+type StringList []string
+
+// Set appends s to the list.
+func (l *StringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// String reports the StringList as a Go value.
+func (l StringList) String() string {
+	return fmt.Sprintf("%#v", []string(l))
+}
+
+// splitEnvCondition splits a "VAR=value" condition into the variable name and
+// value.
+func splitEnvCondition(option, cond string) (string, string, error) {
+	fields := strings.SplitN(cond, "=", 2)
+	if len(fields) != 2 || fields[0] == "" {
+		return "", "", fmt.Errorf("%s=%s is not of the form VAR=value", option, cond)
+	}
+	return fields[0], fields[1], nil
+}
+
+// checkEnvConditions evaluates the ENV_SET, ENV_EQUALS, and ENV_MATCHES
+// conditions. On success, the returned reason describes every condition that
+// was met. Otherwise, the reason describes the first condition that was not.
+func (r *Runner) checkEnvConditions() (string, bool, error) {
+	reason := ""
+	if r.Assigned.Assigned("ENV_SET") {
+		for _, name := range r.EnvSet {
+			if _, ok := r.env[name]; !ok {
+				return fmt.Sprintf("RUN:false ENV_SET=%s is not set", name), false, nil
+			}
+			reason += fmt.Sprintf(" AND ENV_SET=%s is set", name)
+		}
+	}
+	if r.Assigned.Assigned("ENV_EQUALS") {
+		for _, cond := range r.EnvEquals {
+			name, want, err := splitEnvCondition("ENV_EQUALS", cond)
+			if err != nil {
+				return "", false, err
+			}
+			if got := r.env[name]; got != want {
+				return fmt.Sprintf("RUN:false ENV_EQUALS=%s does not equal current value (%s)", cond, got), false, nil
+			}
+			reason += fmt.Sprintf(" AND ENV_EQUALS=%s", cond)
+		}
+	}
+	if r.Assigned.Assigned("ENV_MATCHES") {
+		for _, cond := range r.EnvMatches {
+			name, expr, err := splitEnvCondition("ENV_MATCHES", cond)
+			if err != nil {
+				return "", false, err
+			}
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return "", false, fmt.Errorf("ENV_MATCHES=%s has invalid regular expression: %w", cond, err)
+			}
+			if got := r.env[name]; !re.MatchString(got) {
+				return fmt.Sprintf("RUN:false ENV_MATCHES=%s does not match current value (%s)", cond, got), false, nil
+			}
+			reason += fmt.Sprintf(" AND ENV_MATCHES=%s matches %q", cond, r.env[name])
+		}
+	}
+	return reason, true, nil
+}
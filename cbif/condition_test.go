@@ -0,0 +1,100 @@
+package cbif
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRunner_shouldRun_envConditions(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     Env
+		want    bool
+		reason  string
+		wantErr bool
+	}{
+		{
+			name:   "env-set",
+			env:    Env{"ENV_SET": "TAG_NAME", "TAG_NAME": "v1.0.0"},
+			want:   true,
+			reason: "RUN:true AND ENV_SET=TAG_NAME is set",
+		},
+		{
+			name:   "env-set-empty-value-is-set",
+			env:    Env{"ENV_SET": "TAG_NAME", "TAG_NAME": ""},
+			want:   true,
+			reason: "ENV_SET=TAG_NAME is set",
+		},
+		{
+			name:   "env-not-set",
+			env:    Env{"ENV_SET": "TAG_NAME,_PR_NUMBER", "TAG_NAME": "v1.0.0"},
+			reason: "RUN:false ENV_SET=_PR_NUMBER is not set",
+		},
+		{
+			name:   "env-equals",
+			env:    Env{"ENV_EQUALS": "_DEPLOY_TARGET=prod", "_DEPLOY_TARGET": "prod"},
+			want:   true,
+			reason: "AND ENV_EQUALS=_DEPLOY_TARGET=prod",
+		},
+		{
+			name:   "env-does-not-equal",
+			env:    Env{"ENV_EQUALS": "_DEPLOY_TARGET=prod", "_DEPLOY_TARGET": "staging"},
+			reason: "RUN:false ENV_EQUALS=_DEPLOY_TARGET=prod does not equal current value (staging)",
+		},
+		{
+			name:   "env-equals-with-comma",
+			env:    Env{"ENV_EQUALS": "_TARGETS=a,b", "_TARGETS": "a,b"},
+			want:   true,
+			reason: "AND ENV_EQUALS=_TARGETS=a,b",
+		},
+		{
+			name:   "env-matches-with-comma",
+			env:    Env{"ENV_MATCHES": "TRIGGER_NAME=^deploy-[a-z]{1,8}$", "TRIGGER_NAME": "deploy-prod"},
+			want:   true,
+			reason: `AND ENV_MATCHES=TRIGGER_NAME=^deploy-[a-z]{1,8}$ matches "deploy-prod"`,
+		},
+		{
+			name:   "env-does-not-match",
+			env:    Env{"ENV_MATCHES": "TRIGGER_NAME=^deploy-", "TRIGGER_NAME": "push-main"},
+			reason: "RUN:false ENV_MATCHES=TRIGGER_NAME=^deploy- does not match current value (push-main)",
+		},
+		{
+			name: "all-conditions",
+			env: Env{
+				"PROJECT_ID":     "mlab-oti",
+				"PROJECT_IN":     "mlab-oti",
+				"ENV_SET":        "TAG_NAME",
+				"ENV_EQUALS":     "_DEPLOY_TARGET=prod",
+				"TAG_NAME":       "v1.0.0",
+				"_DEPLOY_TARGET": "prod",
+			},
+			want:   true,
+			reason: `RUN:true AND PROJECT_IN=[]string{"mlab-oti"} contains "mlab-oti" AND ENV_SET=TAG_NAME is set AND ENV_EQUALS=_DEPLOY_TARGET=prod`,
+		},
+		{
+			name:    "error-missing-value",
+			env:     Env{"ENV_EQUALS": "_DEPLOY_TARGET"},
+			wantErr: true,
+		},
+		{
+			name:    "error-invalid-regexp",
+			env:     Env{"ENV_MATCHES": "TRIGGER_NAME=("},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRunner(newTestConfig(t, tt.env), tt.env)
+			reason, run, err := r.shouldRun()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("shouldRun() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if run != tt.want {
+				t.Errorf("shouldRun() = %t, want %t", run, tt.want)
+			}
+			if !strings.Contains(reason, tt.reason) {
+				t.Errorf("shouldRun() reason = %q, want %q", reason, tt.reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright © 2019 gcp-config Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cbif
+
+import (
+	"flag"
+	"time"
+
+	"github.com/m-lab/go/flagx"
+	"github.com/m-lab/go/logx"
+)
+
+// Config contains all cbif options. Each option corresponds to a flag and an
+// environment variable, e.g. ProjectIn is "-project-in" or "PROJECT_IN".
+//
+// A Config is normally populated by RegisterFlags and AssignedFlags. A Config
+// built by hand must also list the options it sets in Assigned, since most
+// conditions and setup actions are ignored unless assigned.
+type Config struct {
+	IgnoreErrors bool
+	// CommandTimeout limits the run time of each command. Zero means no timeout.
+	CommandTimeout time.Duration
+	SingleCommand  bool
+	DryRun         bool
+
+	ProjectIn flagx.StringArray
+	BranchIn  flagx.StringArray
+
+	// EnvSet, EnvEquals, and EnvMatches are conditions on arbitrary
+	// environment variables, e.g. Cloud Build substitutions. Values of
+	// EnvEquals and EnvMatches may contain commas.
+	EnvSet     flagx.StringArray
+	EnvEquals  StringList
+	EnvMatches StringList
+
+	// RunIfStep conditions on the outcome of earlier steps recorded by StepID.
+	RunIfStep flagx.StringArray
+	StepID    string
+	StepState string
+
+	// Dir is the working directory for setup and commands. Default is the
+	// current working directory.
+	Dir           string
+	Workspace     string
+	WorkspaceLink string
+	WorkspaceMode flagx.Enum
+	GitOriginURL  string
+	CommitSHA     string
+
+	CacheKey      string
+	CacheKeyFiles flagx.StringArray
+	CachePaths    flagx.StringArray
+	CacheStore    string
+
+	NotifyWebhook string
+	NotifyStep    string
+	NotifyOn      flagx.Enum
+	NotifyFormat  flagx.Enum
+
+	Limits    ResourceLimits
+	LogDir    string
+	RenderDir string
+
+	// Assigned records the options that were set explicitly, by environment
+	// variable name, e.g. "PROJECT_IN". Conditions and setup actions only
+	// apply to assigned options.
+	Assigned FoundFlags
+
+	// flags is the FlagSet registered by RegisterFlags, if any.
+	flags *flag.FlagSet
+}
+
+// RegisterFlags registers flags for every Config option on fs.
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	c.flags = fs
+	fs.BoolVar(&c.SingleCommand, "single-command", false, "Run each argument as an individual command.")
+	fs.BoolVar(&c.IgnoreErrors, "ignore-errors", false, "Ignore non-zero exit codes when executing commands.")
+	fs.BoolVar(&c.DryRun, "dry-run", false, "Print the resolved environment, setup actions, and commands without executing anything.")
+	fs.DurationVar(&c.CommandTimeout, "command-timeout", time.Hour, "Individual time out for each command to complete. Zero means no timeout.")
+
+	fs.Var(&c.ProjectIn, "project-in", "Run if the current project is one of the conditional projects.")
+	fs.Var(&c.BranchIn, "branch-in", "Run if the current branch is one of the conditional branches.")
+	fs.Var(&c.EnvSet, "env-set", "Run if all of the named environment variables are set.")
+	fs.Var(&c.EnvEquals, "env-equals", "Run if the environment variable equals the value, as VAR=value.")
+	fs.Var(&c.EnvMatches, "env-matches", "Run if the environment variable matches the regular expression, as VAR=regex.")
+	fs.Var(&c.RunIfStep, "run-if-step", "Run if the earlier step had the given outcome, as <id>:<ran|skipped|failed>.")
+	fs.StringVar(&c.StepID, "step-id", "", "Record the outcome of this step under the given id for RUN_IF_STEP.")
+	fs.StringVar(&c.StepState, "step-state", "", "File of recorded step outcomes. Default $WORKSPACE/.cbif/steps.json.")
+
+	fs.StringVar(&c.WorkspaceLink, "workspace-link", "", "Absolute path to link to the /workspace directory and set PWD to linked directory")
+	fs.StringVar(&c.GitOriginURL, "git-origin-url", "", "Git origin URL suitable for cloning")
+	fs.StringVar(&c.CommitSHA, "commit-sha", "", "Commit SHA of the git commit for the current build.")
+	c.WorkspaceMode = flagx.Enum{
+		Options: []string{WorkspaceModeSymlink, WorkspaceModeCopy, WorkspaceModeOverlay},
+		Value:   WorkspaceModeSymlink,
+	}
+	fs.Var(&c.WorkspaceMode, "workspace-mode", "How to make WORKSPACE available at WORKSPACE_LINK: symlink, copy, or overlay.")
+	fs.StringVar(&c.Workspace, "workspace", "/workspace", "Source workspace directory to link into $GOPATH/src/$PROJECT_ROOT")
+
+	fs.StringVar(&c.CacheKey, "cache-key", "", "Restore and save CACHE_PATHS using this key.")
+	fs.Var(&c.CacheKeyFiles, "cache-key-files", "Append the hash of the contents of these files to CACHE_KEY, e.g. go.sum.")
+	fs.Var(&c.CachePaths, "cache-paths", "Paths to restore before and save after running commands.")
+	fs.StringVar(&c.CacheStore, "cache-store", "", "Location of saved caches: a gs://bucket/prefix or local directory.")
+
+	fs.StringVar(&c.NotifyWebhook, "notify-webhook", "", "URL to POST a notification of the command results.")
+	fs.StringVar(&c.NotifyStep, "notify-step", "", "Name of the current build step to include in notifications.")
+	c.NotifyOn = flagx.Enum{
+		Options: []string{NotifyOnFailure, NotifyOnSuccess, NotifyOnAlways},
+		Value:   NotifyOnFailure,
+	}
+	fs.Var(&c.NotifyOn, "notify-on", "Send notifications on command failure, success, or always.")
+	c.NotifyFormat = flagx.Enum{
+		Options: []string{NotifyFormatJSON, NotifyFormatSlack},
+		Value:   NotifyFormatJSON,
+	}
+	fs.Var(&c.NotifyFormat, "notify-format", "Format of notification messages: json or slack.")
+
+	fs.Var(&c.Limits.Memory, "memory-limit", "Maximum memory for each command, e.g. 4GB. Default unlimited.")
+	fs.DurationVar(&c.Limits.CPUTime, "cpu-time-limit", 0, "Maximum CPU time for each command. Default unlimited.")
+	fs.Uint64Var(&c.Limits.OpenFiles, "max-open-files", 0, "Maximum number of open files for each command. Default unlimited.")
+	fs.StringVar(&c.LogDir, "log-dir", "", "Directory to additionally write the stdout and stderr of each command.")
+	fs.StringVar(&c.RenderDir, "render-dir", ".", "Output directory for templates rendered by the 'render' mode.")
+}
+
+// FoundFlags tracks whether flags were found during flag parsing.
+type FoundFlags map[string]struct{}
+
+// Assigned reports whether the option with the given environment variable
+// name, e.g. "PROJECT_IN", was set.
+func (f FoundFlags) Assigned(k string) bool {
+	_, found := f[k]
+	return found
+}
+
+// AssignedFlags discovers the set of flags specified either directly on the
+// command line or indirectly through the environment.
+func AssignedFlags(fs *flag.FlagSet, env Env) FoundFlags {
+	assigned := make(map[string]struct{})
+	// Assignments from the command line.
+	fs.Visit(func(f *flag.Flag) {
+		logx.Debug.Println("FOUND-FLAG:", flagx.MakeShellVariableName(f.Name))
+		assigned[flagx.MakeShellVariableName(f.Name)] = struct{}{}
+	})
+	// Assignments from the environment.
+	fs.VisitAll(func(f *flag.Flag) {
+		envVarName := flagx.MakeShellVariableName(f.Name)
+		if val, ok := env[envVarName]; ok {
+			logx.Debug.Println("FOUND-ENV :", envVarName, val)
+			assigned[envVarName] = struct{}{}
+		}
+	})
+	return assigned
+}
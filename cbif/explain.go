@@ -0,0 +1,154 @@
+// Copyright © 2019 gcp-config Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cbif
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+
+	"github.com/m-lab/go/flagx"
+)
+
+// Explain writes a description of every action Run would take for the given
+// arguments without executing anything.
+func (r *Runner) Explain(w io.Writer, args []string) error {
+	reason, run, err := r.shouldRun()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "# Condition")
+	fmt.Fprintln(w, reason)
+
+	fmt.Fprintln(w, "# Environment")
+	for _, name := range []string{"PROJECT_ID", "BRANCH_NAME"} {
+		fmt.Fprintf(w, "%s=%q\n", name, r.env[name])
+	}
+	if r.flags != nil {
+		r.flags.VisitAll(func(f *flag.Flag) {
+			name := flagx.MakeShellVariableName(f.Name)
+			assigned := ""
+			if r.Assigned.Assigned(name) {
+				assigned = " (assigned)"
+			}
+			fmt.Fprintf(w, "%s=%q%s\n", name, redact(name, f.Value.String()), assigned)
+		})
+	}
+
+	fmt.Fprintln(w, "# Setup")
+	r.explainGit(w)
+	r.explainWorkspaceLink(w)
+	r.explainCache(w)
+	r.explainNotify(w)
+	if r.Assigned.Assigned("LOG_DIR") {
+		fmt.Fprintf(w, "logs: would write command output to files in %q\n", r.LogDir)
+	}
+	if r.Assigned.Assigned("STEP_ID") {
+		fmt.Fprintf(w, "step: would record outcome of %q in %q\n", r.StepID, r.stepStateFile())
+	}
+
+	fmt.Fprintln(w, "# Commands")
+	if !run {
+		fmt.Fprintln(w, "# NOTE: conditions are not met; the commands below would be skipped.")
+	}
+	if len(args) > 0 && args[0] == RenderMode {
+		fmt.Fprintf(w, "render: would render %q into %q\n", args[1:], r.RenderDir)
+		return nil
+	}
+	commands, err := r.prepareCommands(args)
+	if err != nil {
+		return err
+	}
+	for _, command := range commands {
+		fmt.Fprintf(w, "Command: %q\n", command)
+	}
+	return nil
+}
+
+// secretOptions are options whose whole value is a credential, e.g. incoming
+// webhook URLs.
+var secretOptions = map[string]bool{"NOTIFY_WEBHOOK": true}
+
+// redact hides credentials in the value of the named option, since explain
+// output is written to build logs.
+func redact(name, value string) string {
+	if value == "" {
+		return value
+	}
+	u, err := url.Parse(value)
+	if secretOptions[name] {
+		if err == nil && u.Host != "" {
+			return u.Scheme + "://" + u.Host + "/REDACTED"
+		}
+		return "REDACTED"
+	}
+	// URLs of other options, e.g. GIT_ORIGIN_URL, may include a token.
+	if err == nil && u.User != nil {
+		u.User = url.User("REDACTED")
+		return u.String()
+	}
+	return value
+}
+
+func (r *Runner) explainGit(w io.Writer) {
+	_, gitErr := os.Stat(r.path(".git"))
+	switch {
+	case gitErr == nil:
+		fmt.Fprintln(w, "git: .git exists; no setup needed")
+	case r.Assigned.Assigned("GIT_ORIGIN_URL") && r.Assigned.Assigned("COMMIT_SHA"):
+		fmt.Fprintf(w, "git: would create .git from %q at %q\n", redact("GIT_ORIGIN_URL", r.GitOriginURL), r.CommitSHA)
+	default:
+		fmt.Fprintln(w, "git: .git missing; GIT_ORIGIN_URL and COMMIT_SHA not both assigned; no setup")
+	}
+}
+
+func (r *Runner) explainWorkspaceLink(w io.Writer) {
+	if !r.Assigned.Assigned("WORKSPACE_LINK") {
+		fmt.Fprintln(w, "workspace: WORKSPACE_LINK not assigned; no setup")
+		return
+	}
+	switch r.WorkspaceMode.Value {
+	case WorkspaceModeCopy:
+		fmt.Fprintf(w, "workspace: would copy %q to %q and sync changes back after commands\n", r.Workspace, r.WorkspaceLink)
+	case WorkspaceModeOverlay:
+		fmt.Fprintf(w, "workspace: would mount overlay of %q at %q and sync changes back after commands\n", r.Workspace, r.WorkspaceLink)
+	default:
+		fmt.Fprintf(w, "workspace: would run: ln -s %q %q\n", r.Workspace, r.WorkspaceLink)
+	}
+	fmt.Fprintf(w, "workspace: would change dir and set PWD=%q\n", r.WorkspaceLink)
+}
+
+func (r *Runner) explainCache(w io.Writer) {
+	if !r.Assigned.Assigned("CACHE_KEY") {
+		fmt.Fprintln(w, "cache: CACHE_KEY not assigned; no setup")
+		return
+	}
+	key, err := r.cacheKey()
+	if err != nil {
+		fmt.Fprintf(w, "cache: %v\n", err)
+		return
+	}
+	fmt.Fprintf(w, "cache: would restore %q from %q for paths %q\n", key, r.CacheStore, []string(r.CachePaths))
+	fmt.Fprintf(w, "cache: would save %q after successful commands if not restored\n", key)
+}
+
+func (r *Runner) explainNotify(w io.Writer) {
+	if !r.Assigned.Assigned("NOTIFY_WEBHOOK") {
+		fmt.Fprintln(w, "notify: NOTIFY_WEBHOOK not assigned; no notifications")
+		return
+	}
+	fmt.Fprintf(w, "notify: would POST %s notification on %s\n", r.NotifyFormat.Value, r.NotifyOn.Value)
+}
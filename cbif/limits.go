@@ -0,0 +1,83 @@
+// Copyright © 2019 gcp-config Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cbif
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/m-lab/go/bytecount"
+)
+
+// ResourceLimits are applied to each command run by cbif. Zero values are
+// unlimited.
+type ResourceLimits struct {
+	Memory    bytecount.ByteCount
+	CPUTime   time.Duration
+	OpenFiles uint64
+}
+
+func (l ResourceLimits) enabled() bool {
+	return l.Memory > 0 || l.CPUTime > 0 || l.OpenFiles > 0
+}
+
+// limitsEnv names the environment variable that passes the limits from
+// wrapLimits to ExecLimitedIfRequested in the child process.
+const limitsEnv = "CBIF_LIMITS_EXEC"
+
+// ExecLimitedIfRequested applies resource limits and execs the command when
+// the current process was started by cbif as the wrapper for a command with
+// ResourceLimits. Otherwise, it returns immediately. The wrapper never returns.
+//
+// Commands with ResourceLimits are run by re-executing the current binary, so
+// programs that run them must call ExecLimitedIfRequested at the start of
+// main, and tests at the start of TestMain.
+func ExecLimitedIfRequested() {
+	spec, ok := os.LookupEnv(limitsEnv)
+	if !ok {
+		return
+	}
+	err := execLimited(spec)
+	fmt.Fprintf(os.Stderr, "cbif: failed to run with limits: %v\n", err)
+	os.Exit(126)
+}
+
+// runCommand runs cmd with the given resource limits. The returned string
+// describes a limit the command was killed for exceeding, if any.
+func runCommand(cmd *exec.Cmd, limits ResourceLimits) (string, error) {
+	exceeded := func(*os.ProcessState) string { return "" }
+	if limits.enabled() {
+		// Limits are applied by a wrapper before the command is exec'd, so the
+		// command and every child it forks are limited from the start.
+		var err error
+		if exceeded, err = wrapLimits(cmd, limits); err != nil {
+			return "", err
+		}
+	}
+	if err := cmd.Start(); err != nil {
+		exceeded(nil)
+		return "", err
+	}
+	err := cmd.Wait()
+	return exceeded(cmd.ProcessState), err
+}
+
+func logLimits(limits ResourceLimits) {
+	if limits.enabled() {
+		log.Printf("Limits: memory:%s cpu-time:%s open-files:%d", limits.Memory, limits.CPUTime, limits.OpenFiles)
+	}
+}
@@ -0,0 +1,275 @@
+// Copyright © 2019 gcp-config Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cbif
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// cgroupRoot is the mount point of the unified cgroup v2 hierarchy.
+var cgroupRoot = "/sys/fs/cgroup"
+
+// memoryCgroup is a cgroup v2 group that limits memory for a single command.
+type memoryCgroup struct {
+	dir string
+	// restore undoes changes made to the parent group to create dir.
+	restore func()
+}
+
+// selfCgroup returns the directory of the cgroup v2 group of this process.
+func selfCgroup() (string, error) {
+	b, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	// The cgroup v2 entry has the form "0::/path".
+	for _, line := range strings.Split(string(b), "\n") {
+		if p, ok := strings.CutPrefix(line, "0::"); ok {
+			return filepath.Join(cgroupRoot, p), nil
+		}
+	}
+	return "", fmt.Errorf("no cgroup v2 entry in /proc/self/cgroup")
+}
+
+// newMemoryCgroup creates a cgroup v2 group beside the current process that
+// limits memory use to limit bytes.
+func newMemoryCgroup(limit int64) (*memoryCgroup, error) {
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err != nil {
+		return nil, fmt.Errorf("cgroup v2 not available: %w", err)
+	}
+	parent, err := selfCgroup()
+	if err != nil {
+		return nil, err
+	}
+	cg := &memoryCgroup{restore: func() {}}
+	if err := cg.enableMemory(parent); err != nil {
+		cg.restore()
+		return nil, fmt.Errorf("failed to enable memory controller in %q: %w", parent, err)
+	}
+	if cg.dir, err = os.MkdirTemp(parent, "cbif-"); err != nil {
+		cg.restore()
+		return nil, err
+	}
+	err = os.WriteFile(filepath.Join(cg.dir, "memory.max"), []byte(strconv.FormatInt(limit, 10)), 0644)
+	if err != nil {
+		cg.remove()
+		return nil, err
+	}
+	return cg, nil
+}
+
+// enableMemory enables the memory controller for the children of parent.
+// Since a group with processes may not enable controllers for its children,
+// enableMemory first moves this process into a leaf group of its own.
+func (cg *memoryCgroup) enableMemory(parent string) error {
+	control := filepath.Join(parent, "cgroup.subtree_control")
+	b, err := os.ReadFile(control)
+	if err != nil {
+		return err
+	}
+	for _, c := range strings.Fields(string(b)) {
+		if c == "memory" {
+			return nil
+		}
+	}
+	leaf, err := os.MkdirTemp(parent, "cbif-self-")
+	if err != nil {
+		return err
+	}
+	pid := []byte(strconv.Itoa(os.Getpid()))
+	if err := os.WriteFile(filepath.Join(leaf, "cgroup.procs"), pid, 0644); err != nil {
+		os.Remove(leaf)
+		return err
+	}
+	cg.restore = func() {
+		for _, w := range []struct{ file, value string }{
+			{control, "-memory"},
+			{filepath.Join(parent, "cgroup.procs"), string(pid)},
+		} {
+			if err := os.WriteFile(w.file, []byte(w.value), 0644); err != nil {
+				log.Printf("Failed to restore cgroup %q: %v", w.file, err)
+			}
+		}
+		if err := os.Remove(leaf); err != nil {
+			log.Printf("Failed to remove cgroup %q: %v", leaf, err)
+		}
+	}
+	return os.WriteFile(control, []byte("+memory"), 0644)
+}
+
+// oomKilled reports whether any process in the group was killed for
+// exceeding the memory limit.
+func (cg *memoryCgroup) oomKilled() bool {
+	f, err := os.Open(filepath.Join(cg.dir, "memory.events"))
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 2 && fields[0] == "oom_kill" {
+			return fields[1] != "0"
+		}
+	}
+	return false
+}
+
+func (cg *memoryCgroup) remove() {
+	if err := os.Remove(cg.dir); err != nil {
+		log.Printf("Failed to remove cgroup %q: %v", cg.dir, err)
+	}
+	cg.restore()
+}
+
+// limitSpec describes the limits the wrapper applies before exec'ing Path.
+type limitSpec struct {
+	Path      string
+	Memory    uint64
+	CPUTime   time.Duration
+	OpenFiles uint64
+	Cgroup    string
+}
+
+// execLimited applies the limits in spec to the current process and execs the
+// command with the current arguments and the environment without limitsEnv.
+func execLimited(spec string) error {
+	l := limitSpec{}
+	if err := json.Unmarshal([]byte(spec), &l); err != nil {
+		return err
+	}
+	if l.CPUTime > 0 {
+		// The process receives SIGXCPU at the soft limit, and SIGKILL at the hard limit.
+		secs := uint64(l.CPUTime.Seconds())
+		if secs == 0 {
+			secs = 1
+		}
+		if err := syscall.Setrlimit(syscall.RLIMIT_CPU, &syscall.Rlimit{Cur: secs, Max: secs + 1}); err != nil {
+			return fmt.Errorf("failed to set CPU_TIME_LIMIT: %w", err)
+		}
+	}
+	if l.OpenFiles > 0 {
+		// NB: syscall.Setrlimit also prevents syscall.Exec from restoring the
+		// original RLIMIT_NOFILE.
+		if err := syscall.Setrlimit(syscall.RLIMIT_NOFILE, &syscall.Rlimit{Cur: l.OpenFiles, Max: l.OpenFiles}); err != nil {
+			return fmt.Errorf("failed to set MAX_OPEN_FILES: %w", err)
+		}
+	}
+	if l.Cgroup != "" {
+		procs := filepath.Join(l.Cgroup, "cgroup.procs")
+		if err := os.WriteFile(procs, []byte(strconv.Itoa(os.Getpid())), 0644); err != nil {
+			return fmt.Errorf("failed to join cgroup for MEMORY_LIMIT: %w", err)
+		}
+	}
+	if l.Memory > 0 {
+		// Set last, since it may prevent further allocations by this process.
+		if err := syscall.Setrlimit(syscall.RLIMIT_AS, &syscall.Rlimit{Cur: l.Memory, Max: l.Memory}); err != nil {
+			return fmt.Errorf("failed to set MEMORY_LIMIT: %w", err)
+		}
+	}
+	env := []string{}
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, limitsEnv+"=") {
+			env = append(env, kv)
+		}
+	}
+	return syscall.Exec(l.Path, os.Args, env)
+}
+
+// wrapLimits changes cmd to start this binary as a wrapper, which applies the
+// resource limits in ExecLimitedIfRequested before it execs the original
+// command. Memory is limited with a cgroup v2 group when available, and with
+// RLIMIT_AS otherwise. The returned function reports which limit, if any, the
+// process was killed for exceeding once it has exited, and must be called even
+// if the command fails to start.
+func wrapLimits(cmd *exec.Cmd, limits ResourceLimits) (func(*os.ProcessState) string, error) {
+	if cmd.Err != nil {
+		// E.g. the command was not found.
+		return nil, cmd.Err
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find cbif executable for limits: %w", err)
+	}
+	l := limitSpec{
+		Path:      cmd.Path,
+		Memory:    uint64(limits.Memory),
+		CPUTime:   limits.CPUTime,
+		OpenFiles: limits.OpenFiles,
+	}
+	var cg *memoryCgroup
+	if limits.Memory > 0 {
+		if cg, err = newMemoryCgroup(int64(limits.Memory)); err != nil {
+			log.Printf("Using address space rlimit for MEMORY_LIMIT: %v", err)
+		} else {
+			// The cgroup limits resident memory, so the address space is
+			// left unlimited. Otherwise, allocations fail before an OOM kill.
+			l.Memory = 0
+			l.Cgroup = cg.dir
+		}
+	}
+	b, err := json.Marshal(&l)
+	if err != nil {
+		return nil, err
+	}
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	// The wrapper keeps the original arguments, including argv[0].
+	cmd.Path = self
+	cmd.Env = append(env, limitsEnv+"="+string(b))
+	return func(ps *os.ProcessState) string {
+		oom := false
+		if cg != nil {
+			oom = cg.oomKilled()
+			cg.remove()
+		}
+		return exceededLimit(limits, ps, oom)
+	}, nil
+}
+
+// exceededLimit describes the limit that caused the process to be killed, or
+// returns the empty string if the process was not killed for a limit.
+func exceededLimit(limits ResourceLimits, ps *os.ProcessState, oomKilled bool) string {
+	if ps == nil || ps.Success() {
+		return ""
+	}
+	if oomKilled {
+		// The OOM killer may kill any process of the command, e.g. one in a
+		// pipeline, so the command itself may exit rather than be killed.
+		return fmt.Sprintf("killed for exceeding MEMORY_LIMIT=%s", limits.Memory)
+	}
+	status, ok := ps.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return ""
+	}
+	cpu := ps.UserTime() + ps.SystemTime()
+	switch {
+	case limits.CPUTime > 0 && (status.Signal() == syscall.SIGXCPU ||
+		(status.Signal() == syscall.SIGKILL && cpu >= limits.CPUTime)):
+		return fmt.Sprintf("killed for exceeding CPU_TIME_LIMIT=%s (used %s)", limits.CPUTime, cpu)
+	}
+	return ""
+}
@@ -0,0 +1,29 @@
+package cbif
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func Test_runCommand_memoryCgroup(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("memory cgroups require root")
+	}
+	cg, err := newMemoryCgroup(1 << 30)
+	if err != nil {
+		t.Skipf("memory cgroup not available: %v", err)
+	}
+	cg.remove()
+
+	// tail keeps the whole line of zeros in memory.
+	cmd := exec.Command("sh", "-c", "head -c 256000000 /dev/zero | tail -n 1 | wc -c")
+	exceeded, err := runCommand(cmd, ResourceLimits{Memory: 32 * 1000 * 1000})
+	if err == nil {
+		t.Errorf("runCommand() succeeded; want error")
+	}
+	if want := "killed for exceeding MEMORY_LIMIT="; !strings.HasPrefix(exceeded, want) {
+		t.Errorf("runCommand() exceeded = %q, want %q", exceeded, want)
+	}
+}
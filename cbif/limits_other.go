@@ -0,0 +1,33 @@
+// Copyright © 2019 gcp-config Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+
+package cbif
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// wrapLimits is only supported on linux.
+func wrapLimits(cmd *exec.Cmd, limits ResourceLimits) (func(*os.ProcessState) string, error) {
+	return nil, fmt.Errorf("resource limits are not supported on this platform")
+}
+
+// execLimited is only supported on linux.
+func execLimited(spec string) error {
+	return fmt.Errorf("resource limits are not supported on this platform")
+}
@@ -0,0 +1,67 @@
+package cbif
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	// Commands with limits re-execute the test binary as the wrapper.
+	ExecLimitedIfRequested()
+	os.Exit(m.Run())
+}
+
+func Test_runCommand(t *testing.T) {
+	tests := []struct {
+		name         string
+		limits       ResourceLimits
+		args         []string
+		wantOutput   string
+		wantExceeded string
+		wantErr      bool
+	}{
+		{
+			name: "success-no-limits",
+			args: []string{"true"},
+		},
+		{
+			name:       "success-max-open-files",
+			limits:     ResourceLimits{OpenFiles: 64},
+			args:       []string{"sh", "-c", "ulimit -n"},
+			wantOutput: "64\n",
+		},
+		{
+			name:         "error-cpu-time-exceeded",
+			limits:       ResourceLimits{CPUTime: time.Second},
+			args:         []string{"sh", "-c", "while :; do :; done"},
+			wantExceeded: "killed for exceeding CPU_TIME_LIMIT=1s",
+			wantErr:      true,
+		},
+		{
+			name:    "error-command-not-found",
+			args:    []string{"this-command-does-not-exist"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			cmd := exec.Command(tt.args[0], tt.args[1:]...)
+			cmd.Stdout = out
+			exceeded, err := runCommand(cmd, tt.limits)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("runCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !strings.HasPrefix(exceeded, tt.wantExceeded) {
+				t.Errorf("runCommand() exceeded = %q, want %q", exceeded, tt.wantExceeded)
+			}
+			if tt.wantOutput != "" && out.String() != tt.wantOutput {
+				t.Errorf("runCommand() output = %q, want %q", out.String(), tt.wantOutput)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright © 2019 gcp-config Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cbif
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// unsafeName matches characters that should not appear in log file names.
+var unsafeName = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// commandLogs are the per-command output files written to LOG_DIR.
+type commandLogs struct {
+	Stdout *os.File
+	Stderr *os.File
+}
+
+// logRunID returns an id that distinguishes the log files of this run from
+// those of other runs, e.g. parallel build steps, that start in the same
+// second: the step id when set, or the nanoseconds of the start time.
+func logRunID(start time.Time, stepID string) string {
+	if stepID != "" {
+		return unsafeName.ReplaceAllString(stepID, "_")
+	}
+	return fmt.Sprintf("%09d", start.Nanosecond())
+}
+
+// logFilePrefix returns a unique, sortable file name prefix for the i-th
+// command of a run, e.g. "20190102T030405Z-build-01-go".
+func logFilePrefix(start time.Time, run string, i int, command []string) string {
+	name := unsafeName.ReplaceAllString(filepath.Base(command[0]), "_")
+	return fmt.Sprintf("%s-%s-%02d-%s", start.UTC().Format("20060102T150405Z"), run, i, name)
+}
+
+// openCommandLogs creates the stdout and stderr log files for the i-th command
+// of a run in dir. Existing files are never overwritten.
+func openCommandLogs(dir string, start time.Time, run string, i int, command []string) (*commandLogs, error) {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+	prefix := filepath.Join(dir, logFilePrefix(start, run, i, command))
+	create := func(name string) (*os.File, error) {
+		return os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	}
+	stdout, err := create(prefix + ".stdout.log")
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := create(prefix + ".stderr.log")
+	if err != nil {
+		stdout.Close()
+		return nil, err
+	}
+	return &commandLogs{Stdout: stdout, Stderr: stderr}, nil
+}
+
+// Close closes both log files.
+func (l *commandLogs) Close() error {
+	err := l.Stdout.Close()
+	if serr := l.Stderr.Close(); err == nil {
+		err = serr
+	}
+	return err
+}
+
+// String describes the log file locations for the command summary.
+func (l *commandLogs) String() string {
+	return fmt.Sprintf("stdout:%s stderr:%s", l.Stdout.Name(), l.Stderr.Name())
+}
@@ -0,0 +1,56 @@
+package cbif
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/m-lab/go/rtx"
+)
+
+func Test_openCommandLogs(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs")
+	start := time.Date(2019, time.January, 2, 3, 4, 5, 0, time.UTC)
+
+	logs, err := openCommandLogs(dir, start, "build", 1, []string{"/usr/bin/go", "test", "./..."})
+	rtx.Must(err, "failed to open command logs")
+	logs.Stdout.WriteString("stdout")
+	logs.Stderr.WriteString("stderr")
+	rtx.Must(logs.Close(), "failed to close command logs")
+
+	expected := map[string]string{
+		"20190102T030405Z-build-01-go.stdout.log": "stdout",
+		"20190102T030405Z-build-01-go.stderr.log": "stderr",
+	}
+	for name, want := range expected {
+		if got := readFile(t, filepath.Join(dir, name)); got != want {
+			t.Errorf("openCommandLogs() %q = %q, want %q", name, got, want)
+		}
+	}
+
+	// Existing log files are not overwritten.
+	if _, err := openCommandLogs(dir, start, "build", 1, []string{"go"}); err == nil {
+		t.Errorf("openCommandLogs() should fail when log files exist")
+	}
+	if got := readFile(t, filepath.Join(dir, "20190102T030405Z-build-01-go.stdout.log")); got != "stdout" {
+		t.Errorf("openCommandLogs() overwrote existing log file: %q", got)
+	}
+
+	// Log directory cannot be created.
+	f := filepath.Join(t.TempDir(), "file")
+	rtx.Must(os.WriteFile(f, nil, 0666), "failed to write file")
+	if _, err := openCommandLogs(f, start, "build", 0, []string{"true"}); err == nil {
+		t.Errorf("openCommandLogs() should fail when dir is a file")
+	}
+}
+
+func Test_logRunID(t *testing.T) {
+	start := time.Date(2019, time.January, 2, 3, 4, 5, 1234, time.UTC)
+	if got := logRunID(start, "deploy prod"); got != "deploy_prod" {
+		t.Errorf("logRunID() = %q, want %q", got, "deploy_prod")
+	}
+	if got := logRunID(start, ""); got != "000001234" {
+		t.Errorf("logRunID() = %q, want %q", got, "000001234")
+	}
+}
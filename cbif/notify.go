@@ -0,0 +1,179 @@
+// Copyright © 2019 gcp-config Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cbif
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Supported values for NOTIFY_ON and NOTIFY_FORMAT.
+const (
+	NotifyOnFailure = "failure"
+	NotifyOnSuccess = "success"
+	NotifyOnAlways  = "always"
+
+	NotifyFormatJSON  = "json"
+	NotifyFormatSlack = "slack"
+)
+
+// notifyTailBytes is the amount of command output included in notifications.
+const notifyTailBytes = 2048
+
+// tailBuffer is an io.Writer that keeps only the last max bytes written.
+type tailBuffer struct {
+	mu  sync.Mutex
+	max int
+	buf []byte
+}
+
+func newTailBuffer(max int) *tailBuffer {
+	return &tailBuffer{max: max}
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > t.max {
+		t.buf = t.buf[len(t.buf)-t.max:]
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.buf)
+}
+
+// notification describes the outcome of the commands run by cbif.
+type notification struct {
+	Project  string   `json:"project"`
+	Branch   string   `json:"branch"`
+	BuildID  string   `json:"build_id,omitempty"`
+	Step     string   `json:"step,omitempty"`
+	Status   string   `json:"status"`
+	Command  []string `json:"command,omitempty"`
+	ExitCode int      `json:"exit_code"`
+	Output   string   `json:"output,omitempty"`
+}
+
+// newNotification creates a notification for the given command result using
+// the build environment.
+func newNotification(env Env, step string, command []string, code int, output string) *notification {
+	status := NotifyOnSuccess
+	if code != 0 {
+		status = NotifyOnFailure
+	}
+	return &notification{
+		Project:  env["PROJECT_ID"],
+		Branch:   env["BRANCH_NAME"],
+		BuildID:  env["BUILD_ID"],
+		Step:     step,
+		Status:   status,
+		Command:  command,
+		ExitCode: code,
+		Output:   output,
+	}
+}
+
+// slackMessage formats the notification as a Slack incoming webhook message.
+func (n *notification) slackMessage() map[string]string {
+	text := fmt.Sprintf("*cbif %s* project:`%s` branch:`%s`", n.Status, n.Project, n.Branch)
+	if n.Step != "" {
+		text += fmt.Sprintf(" step:`%s`", n.Step)
+	}
+	if n.BuildID != "" {
+		text += fmt.Sprintf(" build:`%s`", n.BuildID)
+	}
+	if len(n.Command) > 0 {
+		text += fmt.Sprintf("\ncommand: `%s` exit code: %d", strings.Join(n.Command, " "), n.ExitCode)
+	}
+	if n.Output != "" {
+		text += "\n```\n" + n.Output + "\n```"
+	}
+	return map[string]string{"text": text}
+}
+
+// shouldNotify reports whether a notification should be sent for the given
+// NOTIFY_ON value and command result.
+func shouldNotify(on string, success bool) bool {
+	switch on {
+	case NotifyOnAlways:
+		return true
+	case NotifyOnSuccess:
+		return success
+	default:
+		return !success
+	}
+}
+
+// sendNotification POSTs the notification to url in the given format.
+func sendNotification(ctx context.Context, url, format string, n *notification) error {
+	var msg interface{} = n
+	if format == NotifyFormatSlack {
+		msg = n.slackMessage()
+	}
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("notification failed with status: %s", resp.Status)
+	}
+	return nil
+}
+
+// tryNotify sends a notification of the command result when NOTIFY_WEBHOOK is
+// assigned and NOTIFY_ON matches the result. Failed commands are reported as
+// failures even when IGNORE_ERRORS is set. Failure to notify is logged but
+// does not change the exit code.
+func (r *Runner) tryNotify(result CommandResult, output string) {
+	if !r.Assigned.Assigned("NOTIFY_WEBHOOK") || !shouldNotify(r.NotifyOn.Value, !result.failed()) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	n := newNotification(r.env, r.NotifyStep, result.Args, result.ExitCode, output)
+	if err := sendNotification(ctx, r.NotifyWebhook, r.NotifyFormat.Value, n); err != nil {
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			// Do not log the webhook URL, which is a credential.
+			err = uerr.Err
+		}
+		log.Printf("Failed to send notification: %v", err)
+		return
+	}
+	log.Printf("Sent %s notification", n.Status)
+}
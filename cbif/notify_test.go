@@ -0,0 +1,97 @@
+package cbif
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_sendNotification(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		status  int
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "success-json",
+			format: NotifyFormatJSON,
+			status: http.StatusOK,
+			want: []string{
+				`"project":"mlab-sandbox"`, `"branch":"main"`, `"step":"deploy"`,
+				`"status":"failure"`, `"command":["false"]`, `"exit_code":1`, `"output":"tail"`,
+			},
+		},
+		{
+			name:   "success-slack",
+			format: NotifyFormatSlack,
+			status: http.StatusOK,
+			want:   []string{`"text":"*cbif failure* project:`},
+		},
+		{
+			name:    "error-status",
+			format:  NotifyFormatJSON,
+			status:  http.StatusInternalServerError,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body string
+			srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				b, _ := io.ReadAll(req.Body)
+				body = string(b)
+				rw.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+			env := Env{"PROJECT_ID": "mlab-sandbox", "BRANCH_NAME": "main"}
+			n := newNotification(env, "deploy", []string{"false"}, 1, "tail")
+			err := sendNotification(context.Background(), srv.URL, tt.format, n)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("sendNotification() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !json.Valid([]byte(body)) {
+				t.Errorf("sendNotification() sent invalid json: %q", body)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("sendNotification() body missing %q; got %s", want, body)
+				}
+			}
+		})
+	}
+}
+
+func Test_shouldNotify(t *testing.T) {
+	tests := []struct {
+		on      string
+		success bool
+		want    bool
+	}{
+		{on: NotifyOnFailure, success: false, want: true},
+		{on: NotifyOnFailure, success: true, want: false},
+		{on: NotifyOnSuccess, success: true, want: true},
+		{on: NotifyOnSuccess, success: false, want: false},
+		{on: NotifyOnAlways, success: true, want: true},
+		{on: NotifyOnAlways, success: false, want: true},
+	}
+	for _, tt := range tests {
+		if got := shouldNotify(tt.on, tt.success); got != tt.want {
+			t.Errorf("shouldNotify(%q, %t) = %t, want %t", tt.on, tt.success, got, tt.want)
+		}
+	}
+}
+
+func Test_tailBuffer(t *testing.T) {
+	b := newTailBuffer(5)
+	b.Write([]byte("abc"))
+	b.Write([]byte("defg"))
+	if got := b.String(); got != "cdefg" {
+		t.Errorf("tailBuffer.String() = %q, want %q", got, "cdefg")
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright © 2019 gcp-config Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cbif
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// templateFuncs returns the functions available to all templates. The
+// conditional functions allow project or branch specific blocks, e.g.
+//
+//	{{if projectIn "mlab-sandbox" "mlab-staging"}}replicas: 1{{end}}
+func templateFuncs(env Env) template.FuncMap {
+	return template.FuncMap{
+		"projectIn": func(projects ...string) bool {
+			return contains(projects, env["PROJECT_ID"])
+		},
+		"branchIn": func(branches ...string) bool {
+			return contains(branches, env["BRANCH_NAME"])
+		},
+		// env returns the named environment variable, or an error if it is unset.
+		"env": func(name string) (string, error) {
+			v, ok := env[name]
+			if !ok {
+				return "", fmt.Errorf("environment variable %q is not set", name)
+			}
+			return v, nil
+		},
+	}
+}
+
+// renderOutputPath returns the output path for the given template file. The
+// ".tmpl" suffix is removed and relative template paths are preserved under
+// outDir.
+func renderOutputPath(outDir, root, file string) (string, error) {
+	rel := filepath.Base(file)
+	if root != "" {
+		var err error
+		if rel, err = filepath.Rel(root, file); err != nil {
+			return "", err
+		}
+	} else if !filepath.IsAbs(file) {
+		rel = filepath.Clean(file)
+	}
+	if strings.HasPrefix(rel, "..") {
+		rel = filepath.Base(file)
+	}
+	return filepath.Join(outDir, strings.TrimSuffix(rel, ".tmpl")), nil
+}
+
+// renderFile renders a single template file to the output path. Missing keys
+// are an error.
+func renderFile(file, output string, env Env) error {
+	if filepath.Clean(file) == filepath.Clean(output) {
+		return fmt.Errorf("refusing to overwrite template with rendered output")
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	tmpl, err := template.New(filepath.Base(file)).Option("missingkey=error").Funcs(templateFuncs(env)).Parse(string(b))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(output), 0777); err != nil {
+		return err
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	err = tmpl.Execute(f, env)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		// Do not leave partially rendered output for later steps.
+		os.Remove(output)
+		return err
+	}
+	log.Printf("Rendered: %s -> %s", file, output)
+	return nil
+}
+
+// renderTemplates renders every named template file, or every file within a
+// named directory, into outDir using env as data.
+func renderTemplates(outDir string, inputs []string, env Env) error {
+	if len(inputs) == 0 {
+		return fmt.Errorf("no templates given to render")
+	}
+	for _, input := range inputs {
+		info, err := os.Stat(input)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			output, err := renderOutputPath(outDir, "", input)
+			if err != nil {
+				return err
+			}
+			if err := renderFile(input, output, env); err != nil {
+				return fmt.Errorf("%s: %w", input, err)
+			}
+			continue
+		}
+		err = filepath.Walk(input, func(file string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			output, err := renderOutputPath(outDir, input, file)
+			if err != nil {
+				return err
+			}
+			if err := renderFile(file, output, env); err != nil {
+				return fmt.Errorf("%s: %w", file, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package cbif
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/m-lab/go/rtx"
+)
+
+func Test_renderTemplates(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      Env
+		files    map[string]string
+		inputs   []string
+		expected map[string]string
+		wantErr  bool
+	}{
+		{
+			name: "success-file",
+			env:  map[string]string{"PROJECT_ID": "mlab-sandbox"},
+			files: map[string]string{
+				"deploy.yaml.tmpl": "project: {{.PROJECT_ID}}",
+			},
+			inputs: []string{"deploy.yaml.tmpl"},
+			expected: map[string]string{
+				"deploy.yaml": "project: mlab-sandbox",
+			},
+		},
+		{
+			name: "success-directory-with-project-blocks",
+			env:  map[string]string{"PROJECT_ID": "mlab-oti", "BRANCH_NAME": "main"},
+			files: map[string]string{
+				"k8s/a.yaml.tmpl":     `{{if projectIn "mlab-sandbox" "mlab-staging"}}replicas: 1{{else}}replicas: 3{{end}}`,
+				"k8s/sub/b.yaml.tmpl": `{{if branchIn "main"}}branch: {{env "BRANCH_NAME"}}{{end}}`,
+			},
+			inputs: []string{"k8s"},
+			expected: map[string]string{
+				"a.yaml":     "replicas: 3",
+				"sub/b.yaml": "branch: main",
+			},
+		},
+		{
+			name: "error-missing-key",
+			files: map[string]string{
+				"missing.tmpl": "{{.THIS_VARIABLE_IS_NOT_DEFINED}}",
+			},
+			inputs:  []string{"missing.tmpl"},
+			wantErr: true,
+		},
+		{
+			name: "error-missing-env",
+			files: map[string]string{
+				"missing.tmpl": `{{env "THIS_VARIABLE_IS_NOT_DEFINED"}}`,
+			},
+			inputs:  []string{"missing.tmpl"},
+			wantErr: true,
+		},
+		{
+			name:    "error-no-inputs",
+			wantErr: true,
+		},
+		{
+			name:    "error-input-does-not-exist",
+			inputs:  []string{"does-not-exist.tmpl"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			outDir := filepath.Join(dir, "output")
+			for name, content := range tt.files {
+				p := filepath.Join(dir, name)
+				rtx.Must(os.MkdirAll(filepath.Dir(p), 0777), "failed to create dir")
+				rtx.Must(os.WriteFile(p, []byte(content), 0666), "failed to write file")
+			}
+			inputs := []string{}
+			for _, input := range tt.inputs {
+				inputs = append(inputs, filepath.Join(dir, input))
+			}
+
+			err := renderTemplates(outDir, inputs, tt.env)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("renderTemplates() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for name, want := range tt.expected {
+				b, err := os.ReadFile(filepath.Join(outDir, name))
+				if err != nil {
+					t.Errorf("renderTemplates() failed to read output %q: %v", name, err)
+					continue
+				}
+				if string(b) != want {
+					t.Errorf("renderTemplates() %q = %q, want %q", name, string(b), want)
+				}
+			}
+		})
+	}
+}
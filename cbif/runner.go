@@ -0,0 +1,413 @@
+// Copyright © 2019 gcp-config Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cbif implements conditional command execution for Cloud Build
+// steps. The cbif command is a thin wrapper around the Runner in this package.
+package cbif
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/shlex"
+	"gopkg.in/m-lab/pipe.v3"
+)
+
+// Modes selected by the first argument to Run.
+const (
+	// ExplainMode is equivalent to Config.DryRun.
+	ExplainMode = "explain"
+	// RenderMode renders templates instead of running commands.
+	RenderMode = "render"
+)
+
+// Env is the set of environment variables used to evaluate conditions, render
+// templates, and run commands.
+type Env map[string]string
+
+// OSEnv returns the current process environment.
+func OSEnv() Env {
+	env := Env{}
+	for _, kv := range os.Environ() {
+		fields := strings.SplitN(kv, "=", 2)
+		env[fields[0]] = fields[1]
+	}
+	return env
+}
+
+// Environ returns the environment in "key=value" form, sorted by key.
+func (e Env) Environ() []string {
+	environ := make([]string, 0, len(e))
+	for k, v := range e {
+		environ = append(environ, k+"="+v)
+	}
+	sort.Strings(environ)
+	return environ
+}
+
+// Result describes the outcome of Run.
+type Result struct {
+	// Ran is true when all conditions were met.
+	Ran bool
+	// Reason explains the evaluation of conditions.
+	Reason string
+	// Commands are the results of each command that ran.
+	Commands []CommandResult
+	// ExitCode is the code cbif should exit with.
+	ExitCode int
+}
+
+// CommandResult describes the outcome of a single command.
+type CommandResult struct {
+	Args     []string
+	ExitCode int
+	// Exceeded describes the resource limit the command was killed for
+	// exceeding, if any.
+	Exceeded string
+	Err      error
+}
+
+// failed reports whether the command failed, even if the failure was ignored.
+func (c CommandResult) failed() bool {
+	return c.ExitCode != 0 || c.Err != nil
+}
+
+// Runner evaluates conditions, prepares the environment, and runs commands
+// according to its Config.
+type Runner struct {
+	Config
+	Stdout io.Writer
+	Stderr io.Writer
+
+	env Env
+	dir string
+}
+
+// NewRunner creates a new Runner. Conditions, templates and commands use the
+// given environment rather than the process environment.
+func NewRunner(c Config, env Env) *Runner {
+	r := &Runner{
+		Config: c,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+		env:    Env{},
+		dir:    c.Dir,
+	}
+	for k, v := range env {
+		r.env[k] = v
+	}
+	if r.dir == "" {
+		r.dir, _ = os.Getwd()
+	}
+	return r
+}
+
+// path returns p relative to the current working directory of the runner.
+func (r *Runner) path(p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(r.dir, p)
+}
+
+func (r *Runner) shouldRun() (string, bool, error) {
+	project := r.env["PROJECT_ID"]
+	if r.Assigned.Assigned("PROJECT_IN") && !r.ProjectIn.Contains(project) {
+		return fmt.Sprintf("RUN:false PROJECT_IN=%v does not include current project (%s)",
+			r.ProjectIn, project), false, nil
+	}
+	branch := r.env["BRANCH_NAME"]
+	if r.Assigned.Assigned("BRANCH_IN") && !r.BranchIn.Contains(branch) {
+		return fmt.Sprintf("RUN:false BRANCH_IN=%v does not include current branch (%s)",
+			r.BranchIn, branch), false, nil
+	}
+	envReason, run, err := r.checkEnvConditions()
+	if err != nil || !run {
+		return envReason, run, err
+	}
+	stepReason, run, err := r.checkStepConditions()
+	if err != nil || !run {
+		return stepReason, run, err
+	}
+
+	reason := "RUN:true"
+	if r.Assigned.Assigned("PROJECT_IN") {
+		reason += fmt.Sprintf(" AND PROJECT_IN=%v contains %q", r.ProjectIn, project)
+	}
+	if r.Assigned.Assigned("BRANCH_IN") {
+		reason += fmt.Sprintf(" AND BRANCH_IN=%v contains %q", r.BranchIn, branch)
+	}
+	return reason + envReason + stepReason, true, nil
+}
+
+func (r *Runner) trySetupGit() error {
+	_, gitErr := os.Stat(r.path(".git"))
+	if gitErr != nil && (r.Assigned.Assigned("GIT_ORIGIN_URL") && r.Assigned.Assigned("COMMIT_SHA")) {
+		// Setup the .git repo if it's missing and we have the necessary info.
+		if err := r.createGit(r.GitOriginURL, r.CommitSHA); err != nil {
+			return fmt.Errorf("failed to create .git directory: %w", err)
+		}
+	}
+	return nil
+}
+
+// trySetupWorkspaceLink returns a function to complete the workspace setup
+// after all commands have run.
+func (r *Runner) trySetupWorkspaceLink() (func() error, error) {
+	if !r.Assigned.Assigned("WORKSPACE_LINK") {
+		return func() error { return nil }, nil
+	}
+	finish, err := linkWorkspace(r.WorkspaceMode.Value, r.Workspace, r.WorkspaceLink)
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup workspace: %q -> %q: %w", r.WorkspaceLink, r.Workspace, err)
+	}
+	// The process cwd maintained by the Linux kernel is the real, physical
+	// path. We want processes to execute with a cwd within the symbolically
+	// linked directory. Most shells manage PWD / OLDPWD in environment
+	// variables independent of the kernel and libc. The Go os.Getwd() follows
+	// this convention, by returning PWD if found, or using Getwd syscall
+	// otherwise. By setting PWD, we allow processes that use this convention to
+	// use the symlinked directory as the current working directory.
+	r.dir = r.WorkspaceLink
+	r.env["PWD"] = r.WorkspaceLink
+	return finish, nil
+}
+
+func (r *Runner) createGit(originURL, sha string) error {
+	b, err := pipe.CombinedOutput(
+		pipe.Script("# Creating .git from "+redact("GIT_ORIGIN_URL", originURL),
+			pipe.ChDir(r.dir),
+			pipe.Exec("git", "init"),
+			pipe.Exec("git", "remote", "add", "origin", originURL),
+			pipe.Exec("git", "fetch", "--depth=1", "origin", sha),
+			pipe.Exec("git", "reset", "--hard", "FETCH_HEAD"),
+		),
+	)
+	fmt.Fprintln(r.Stdout, string(b))
+	return err
+}
+
+func (r *Runner) prepareCommands(args []string) ([][]string, error) {
+	commands := [][]string{}
+	if r.SingleCommand {
+		if len(args) > 0 {
+			commands = append(commands, args)
+		}
+		return commands, nil
+	}
+	for _, arg := range args {
+		command, err := shlex.Split(arg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split command: %q: %w", arg, err)
+		}
+		commands = append(commands, command)
+	}
+	return commands, nil
+}
+
+func (r *Runner) createCmd(ctx context.Context, args []string, sout, serr io.Writer) *exec.Cmd {
+	log.Println("Command:", args)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = r.dir
+	cmd.Env = r.env.Environ()
+	cmd.Stdout = sout
+	cmd.Stderr = serr
+	return cmd
+}
+
+// checkExit reports the exit status of a command and returns the exit code
+// that cbif should exit with, or zero to continue. When the command was killed
+// for exceeding a resource limit, exceeded describes the limit.
+func (r *Runner) checkExit(err error, ps *os.ProcessState, exceeded string) int {
+	if ps == nil {
+		// The command could not be started.
+		log.Printf("error: failed to start command: %v\n", err)
+		if r.IgnoreErrors {
+			return 0
+		}
+		return 1
+	}
+	if err == nil {
+		log.Printf("success: pid:%d code:%d\n", ps.Pid(), ps.ExitCode())
+		return 0
+	}
+	if exceeded != "" {
+		log.Printf("error: pid:%d %s err:%s\n", ps.Pid(), exceeded, err.Error())
+	} else {
+		log.Printf("error: pid:%d code:%d err:%s\n", ps.Pid(), ps.ExitCode(), err.Error())
+	}
+	if r.IgnoreErrors {
+		return 0
+	}
+	return ps.ExitCode()
+}
+
+// Run evaluates the configured conditions and, if they are met, prepares the
+// environment and runs each command in args. If the first argument is
+// ExplainMode, or DryRun is set, Run describes the actions it would take
+// without executing anything. If the first argument is RenderMode, the
+// remaining arguments are rendered as templates. Errors preparing the
+// environment are returned, while command failures are reported in the Result.
+// When StepID is assigned, the outcome is recorded for later steps.
+func (r *Runner) Run(ctx context.Context, args []string) (*Result, error) {
+	if len(args) > 0 && args[0] == ExplainMode {
+		r.DryRun = true
+		args = args[1:]
+	}
+	if r.DryRun {
+		return &Result{}, r.Explain(r.Stdout, args)
+	}
+	result, err := r.run(ctx, args)
+	if r.Assigned.Assigned("STEP_ID") {
+		if serr := r.recordStep(result, err); serr != nil && err == nil {
+			err = serr
+		}
+	}
+	return result, err
+}
+
+func (r *Runner) run(ctx context.Context, args []string) (result *Result, err error) {
+	reason, run, err := r.shouldRun()
+	if err != nil {
+		return &Result{}, err
+	}
+	log.Println(reason)
+	result = &Result{Ran: run, Reason: reason}
+	if !run {
+		return result, nil
+	}
+	if err := r.trySetupGit(); err != nil {
+		return result, err
+	}
+	finishWorkspace, err := r.trySetupWorkspaceLink()
+	if err != nil {
+		return result, err
+	}
+	// Always finish the workspace setup, e.g. to unmount an overlay.
+	defer func() {
+		if ferr := finishWorkspace(); ferr != nil {
+			ferr = fmt.Errorf("failed to finish workspace setup: %w", ferr)
+			if err != nil {
+				log.Println(ferr)
+				return
+			}
+			err = ferr
+		}
+	}()
+
+	if len(args) > 0 && args[0] == RenderMode {
+		if err := renderTemplates(r.path(r.RenderDir), r.inputPaths(args[1:]), r.env); err != nil {
+			return result, fmt.Errorf("failed to render templates: %w", err)
+		}
+		return result, nil
+	}
+
+	commands, err := r.prepareCommands(args)
+	if err != nil {
+		return result, err
+	}
+	finishCache, err := r.trySetupCache(ctx)
+	if err != nil {
+		return result, err
+	}
+	result.ExitCode, result.Commands, err = r.runCommands(ctx, commands)
+	if err != nil {
+		return result, err
+	}
+	finishCache(result.ExitCode == 0)
+	return result, nil
+}
+
+func (r *Runner) inputPaths(inputs []string) []string {
+	paths := make([]string, 0, len(inputs))
+	for _, input := range inputs {
+		paths = append(paths, r.path(input))
+	}
+	return paths
+}
+
+// runCommands runs each command in order until one fails, and returns the exit
+// code cbif should exit with.
+func (r *Runner) runCommands(ctx context.Context, commands [][]string) (int, []CommandResult, error) {
+	var cancel context.CancelFunc
+	if r.CommandTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, r.CommandTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	code := 0
+	results := []CommandResult{}
+	// Notify about the first failed command, or the last command if none failed.
+	notify, notifyOutput := -1, ""
+	logLimits(r.Limits)
+	start := time.Now()
+	logRun := logRunID(start, "")
+	if r.Assigned.Assigned("STEP_ID") {
+		logRun = logRunID(start, r.StepID)
+	}
+	for i, command := range commands {
+		var sout, serr io.Writer = r.Stdout, r.Stderr
+		var output *tailBuffer
+		if r.Assigned.Assigned("NOTIFY_WEBHOOK") {
+			// Keep the tail of command output for notifications.
+			output = newTailBuffer(notifyTailBytes)
+			sout, serr = io.MultiWriter(sout, output), io.MultiWriter(serr, output)
+		}
+		var logs *commandLogs
+		if r.Assigned.Assigned("LOG_DIR") {
+			var err error
+			logs, err = openCommandLogs(r.path(r.LogDir), start, logRun, i, command)
+			if err != nil {
+				return 0, results, fmt.Errorf("failed to create log files in %q: %w", r.LogDir, err)
+			}
+			sout, serr = io.MultiWriter(sout, logs.Stdout), io.MultiWriter(serr, logs.Stderr)
+		}
+		cmd := r.createCmd(ctx, command, sout, serr)
+		exceeded, err := runCommand(cmd, r.Limits)
+		if logs != nil {
+			if err := logs.Close(); err != nil {
+				return 0, results, fmt.Errorf("failed to close log files: %w", err)
+			}
+			log.Println("logs:", logs)
+		}
+		code = r.checkExit(err, cmd.ProcessState, exceeded)
+		result := CommandResult{Args: command, Exceeded: exceeded, Err: err}
+		if cmd.ProcessState != nil {
+			result.ExitCode = cmd.ProcessState.ExitCode()
+		} else {
+			result.ExitCode = -1
+		}
+		results = append(results, result)
+		if output != nil && (notify < 0 || !results[notify].failed()) {
+			notify, notifyOutput = i, output.String()
+		}
+		if code != 0 {
+			break
+		}
+	}
+	if notify >= 0 {
+		r.tryNotify(results[notify], notifyOutput)
+	}
+	return code, results, nil
+}
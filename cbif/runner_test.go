@@ -0,0 +1,274 @@
+package cbif
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/m-lab/go/flagx"
+	"github.com/m-lab/go/rtx"
+	"gopkg.in/m-lab/pipe.v3"
+)
+
+// newTestConfig returns a Config with options set from env, as the cbif
+// command would with flagx.ArgsFromEnv.
+func newTestConfig(t *testing.T, env Env) Config {
+	c := Config{}
+	fs := flag.NewFlagSet("fake-cbif", flag.ContinueOnError)
+	c.RegisterFlags(fs)
+	fs.VisitAll(func(f *flag.Flag) {
+		if v, ok := env[flagx.MakeShellVariableName(f.Name)]; ok {
+			rtx.Must(fs.Set(f.Name, v), "failed to set flag %q", f.Name)
+		}
+	})
+	c.Assigned = AssignedFlags(fs, env)
+	return c
+}
+
+func TestRunner_Run(t *testing.T) {
+	// Create a tempdir as a fake /workspace and link target location.
+	tmpdir := t.TempDir()
+
+	// Unpack the fake git data to use during tests.
+	b, err := pipe.CombinedOutput(
+		pipe.Exec("tar", "-C", tmpdir, "-xf", "../testdata/fake.git.tar.gz"),
+	)
+	fmt.Println(string(b))
+	rtx.Must(err, "failed to unpack fake git data")
+
+	// Receive notifications from commands.
+	received := []notification{}
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		n := notification{}
+		rtx.Must(json.NewDecoder(req.Body).Decode(&n), "failed to decode notification")
+		received = append(received, n)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		env        Env
+		args       []string
+		wantRan    bool
+		code       int
+		wantNotify []notification
+	}{
+		{
+			name: "command-runs-using-env-correct-branch",
+			env: Env{
+				"BRANCH_NAME": "correct-branch",
+				"PROJECT_ID":  "correct-project",
+				"BRANCH_IN":   "correct-branch",
+				"PROJECT_IN":  "correct-project",
+			},
+			args:    []string{"echo"},
+			wantRan: true,
+		},
+		{
+			name: "command-does-not-run-wrong-branch",
+			env: Env{
+				"BRANCH_NAME": "wrong-branch",
+				"BRANCH_IN":   "correct-branch",
+			},
+			args: []string{"echo"},
+		},
+		{
+			name: "command-does-not-run-wrong-project",
+			env: Env{
+				"PROJECT_ID": "wrong-project",
+				"PROJECT_IN": "current-project",
+			},
+			args: []string{"echo"},
+		},
+		{
+			name:    "command-runs-and-exists-non-zero",
+			args:    []string{"false"},
+			wantRan: true,
+			code:    1,
+		},
+		{
+			name: "setup-workspace-link-run-success",
+			args: []string{"pwd"},
+			env: Env{
+				"WORKSPACE":      tmpdir,
+				"WORKSPACE_LINK": path.Join(tmpdir, "go/this/is/a/path"),
+			},
+			wantRan: true,
+		},
+		{
+			name: "setup-git-directory",
+			args: []string{"stat", ".git"},
+			env: Env{
+				"WORKSPACE":      tmpdir,
+				"WORKSPACE_LINK": path.Join(tmpdir, "go/this/is/a/path"),
+				"GIT_ORIGIN_URL": tmpdir + "/fake.git",
+				"COMMIT_SHA":     "2a5f2af7fad0af0e1a354f42660a78420cd4751f",
+				"SINGLE_COMMAND": "true",
+			},
+			wantRan: true,
+		},
+		{
+			name: "cache-save-after-success",
+			args: []string{"true"},
+			env: Env{
+				"CACHE_KEY":   "fake-key",
+				"CACHE_STORE": path.Join(tmpdir, "cache"),
+				"CACHE_PATHS": tmpdir + "/fake.git",
+			},
+			wantRan: true,
+		},
+		{
+			name: "notify-on-failure",
+			args: []string{"false"},
+			env: Env{
+				"NOTIFY_WEBHOOK": srv.URL,
+			},
+			wantRan: true,
+			code:    1,
+			wantNotify: []notification{
+				{Status: NotifyOnFailure, Command: []string{"false"}, ExitCode: 1},
+			},
+		},
+		{
+			name: "notify-on-failure-ignore-errors",
+			args: []string{"false", "true"},
+			env: Env{
+				"NOTIFY_WEBHOOK": srv.URL,
+				"IGNORE_ERRORS":  "true",
+			},
+			wantRan: true,
+			wantNotify: []notification{
+				{Status: NotifyOnFailure, Command: []string{"false"}, ExitCode: 1},
+			},
+		},
+		{
+			name: "notify-on-success-ignore-errors-does-not-notify",
+			args: []string{"false", "true"},
+			env: Env{
+				"NOTIFY_WEBHOOK": srv.URL,
+				"NOTIFY_ON":      "success",
+				"IGNORE_ERRORS":  "true",
+			},
+			wantRan: true,
+		},
+		{
+			name: "log-dir-writes-command-output",
+			args: []string{"echo hello", "ls"},
+			env: Env{
+				"LOG_DIR": path.Join(tmpdir, "logs"),
+			},
+			wantRan: true,
+		},
+		{
+			name: "dry-run-does-not-run-command",
+			args: []string{"false"},
+			env: Env{
+				"DRY_RUN": "true",
+			},
+		},
+		{
+			name: "explain-does-not-run-command",
+			args: []string{"explain", "false"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestConfig(t, tt.env)
+			c.Dir = tmpdir
+			received = []notification{}
+
+			result, err := NewRunner(c, tt.env).Run(context.Background(), tt.args)
+			if err != nil {
+				t.Fatalf("Run() unexpected error: %v", err)
+			}
+			if result.Ran != tt.wantRan {
+				t.Errorf("Run() wrong ran; got %t, want %t: %s", result.Ran, tt.wantRan, result.Reason)
+			}
+			if result.ExitCode != tt.code {
+				t.Errorf("Run() wrong exit code; got %d, want %d", result.ExitCode, tt.code)
+			}
+			for i := range received {
+				// Only compare the outcome.
+				received[i].Output = ""
+			}
+			if tt.wantNotify == nil {
+				tt.wantNotify = []notification{}
+			}
+			if diff := deep.Equal(received, tt.wantNotify); diff != nil {
+				t.Errorf("Run() wrong notifications; %v", diff)
+			}
+		})
+	}
+}
+
+func TestRunner_Run_doesNotModifyEnv(t *testing.T) {
+	env := Env{"PATH": "/usr/bin:/bin"}
+	c := newTestConfig(t, Env{"WORKSPACE_LINK": path.Join(t.TempDir(), "link")})
+	c.Workspace = t.TempDir()
+
+	result, err := NewRunner(c, env).Run(context.Background(), []string{"true"})
+	if err != nil || result.ExitCode != 0 {
+		t.Fatalf("Run() = %v, %v; want exit code 0", result, err)
+	}
+	if _, ok := env["PWD"]; ok {
+		t.Errorf("Run() modified the given env: %v", env)
+	}
+}
+
+func TestRunner_Run_configWithoutFlags(t *testing.T) {
+	// A hand-built Config has a zero CommandTimeout and only applies options
+	// listed in Assigned.
+	c := Config{
+		Dir:       t.TempDir(),
+		BranchIn:  flagx.StringArray{"main"},
+		ProjectIn: flagx.StringArray{"other-project"},
+		Assigned:  FoundFlags{"BRANCH_IN": {}},
+	}
+	env := Env{"BRANCH_NAME": "main", "PROJECT_ID": "current-project"}
+
+	result, err := NewRunner(c, env).Run(context.Background(), []string{"true"})
+	if err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+	if !result.Ran || result.ExitCode != 0 {
+		t.Errorf("Run() = %+v; want ran with exit code 0", result)
+	}
+
+	c.Assigned["PROJECT_IN"] = struct{}{}
+	result, err = NewRunner(c, env).Run(context.Background(), []string{"true"})
+	if err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+	if result.Ran {
+		t.Errorf("Run() ran with an unmatched PROJECT_IN: %+v", result)
+	}
+}
+
+func TestRunner_Run_finishesWorkspaceOnError(t *testing.T) {
+	workspace := t.TempDir()
+	env := Env{
+		"WORKSPACE":      workspace,
+		"WORKSPACE_LINK": path.Join(t.TempDir(), "link"),
+		"WORKSPACE_MODE": "copy",
+		"LOG_DIR":        "logs",
+	}
+	c := newTestConfig(t, env)
+
+	// The first command replaces LOG_DIR with a file, so the second fails to
+	// open its log files.
+	args := []string{"sh -c 'touch created.txt && rm -r logs && touch logs'", "true"}
+	_, err := NewRunner(c, env).Run(context.Background(), args)
+	if err == nil {
+		t.Fatalf("Run() succeeded; want error")
+	}
+	if _, err := os.Stat(path.Join(workspace, "created.txt")); err != nil {
+		t.Errorf("Run() did not sync changes back to the workspace: %v", err)
+	}
+}
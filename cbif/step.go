@@ -0,0 +1,162 @@
+// Copyright © 2019 gcp-config Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cbif
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Step outcomes recorded for STEP_ID and checked by RUN_IF_STEP.
+const (
+	StepRan     = "ran"
+	StepSkipped = "skipped"
+	StepFailed  = "failed"
+)
+
+// stepRecord is the recorded outcome of a single cbif step.
+type stepRecord struct {
+	Status string `json:"status"`
+	// Ignored is true when a command failed but IGNORE_ERRORS allowed the step
+	// to succeed.
+	Ignored  bool      `json:"ignored,omitempty"`
+	ExitCode int       `json:"exit_code"`
+	Time     time.Time `json:"time"`
+}
+
+// stepStateFile returns the location of the step state file. By default, the
+// state is kept in the WORKSPACE so that it is shared by all build steps.
+func (r *Runner) stepStateFile() string {
+	if r.StepState != "" {
+		return r.path(r.StepState)
+	}
+	return filepath.Join(r.Workspace, ".cbif", "steps.json")
+}
+
+// readStepState reads the recorded step outcomes. A missing file is empty.
+func readStepState(file string) (map[string]stepRecord, error) {
+	state := map[string]stepRecord{}
+	b, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse step state %q: %w", file, err)
+	}
+	return state, nil
+}
+
+// writeStepRecord adds the record for id to the step state file.
+func writeStepRecord(file, id string, rec stepRecord) error {
+	state, err := readStepState(file)
+	if err != nil {
+		return err
+	}
+	state[id] = rec
+	b, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0777); err != nil {
+		return err
+	}
+	// Write to a temporary file first so readers never see a partial file.
+	f, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(append(b, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), file)
+}
+
+// newStepRecord summarizes the outcome of Run.
+func newStepRecord(result *Result, err error) stepRecord {
+	rec := stepRecord{Status: StepRan, ExitCode: result.ExitCode, Time: time.Now().UTC()}
+	switch {
+	case err != nil:
+		rec.Status = StepFailed
+		if rec.ExitCode == 0 {
+			rec.ExitCode = 1
+		}
+	case !result.Ran:
+		rec.Status = StepSkipped
+	case result.ExitCode != 0:
+		rec.Status = StepFailed
+	default:
+		for _, c := range result.Commands {
+			if c.failed() {
+				rec.Status = StepFailed
+				rec.Ignored = true
+				rec.ExitCode = c.ExitCode
+			}
+		}
+	}
+	return rec
+}
+
+// recordStep saves the outcome of Run for STEP_ID.
+func (r *Runner) recordStep(result *Result, err error) error {
+	rec := newStepRecord(result, err)
+	file := r.stepStateFile()
+	if err := writeStepRecord(file, r.StepID, rec); err != nil {
+		return fmt.Errorf("failed to record step %q in %q: %w", r.StepID, file, err)
+	}
+	return nil
+}
+
+// checkStepConditions evaluates the RUN_IF_STEP conditions against the
+// outcomes recorded by earlier steps.
+func (r *Runner) checkStepConditions() (string, bool, error) {
+	if !r.Assigned.Assigned("RUN_IF_STEP") {
+		return "", true, nil
+	}
+	state, err := readStepState(r.stepStateFile())
+	if err != nil {
+		return "", false, err
+	}
+	reason := ""
+	for _, cond := range r.RunIfStep {
+		fields := strings.SplitN(cond, ":", 2)
+		if len(fields) != 2 || fields[0] == "" {
+			return "", false, fmt.Errorf("RUN_IF_STEP=%s is not of the form <id>:<status>", cond)
+		}
+		id, want := fields[0], fields[1]
+		if want != StepRan && want != StepSkipped && want != StepFailed {
+			return "", false, fmt.Errorf("RUN_IF_STEP=%s has unknown status; want ran, skipped, or failed", cond)
+		}
+		got := "none"
+		if rec, ok := state[id]; ok {
+			got = rec.Status
+		}
+		if got != want {
+			return fmt.Sprintf("RUN:false RUN_IF_STEP=%s does not match step status (%s)", cond, got), false, nil
+		}
+		reason += fmt.Sprintf(" AND RUN_IF_STEP=%s", cond)
+	}
+	return reason, true, nil
+}
@@ -0,0 +1,91 @@
+package cbif
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunner_Run_stepConditions(t *testing.T) {
+	state := filepath.Join(t.TempDir(), "steps.json")
+	steps := []struct {
+		name    string
+		env     Env
+		args    []string
+		wantRan bool
+		want    string
+		ignored bool
+	}{
+		{
+			name:    "build",
+			env:     Env{"STEP_ID": "build", "IGNORE_ERRORS": "true"},
+			args:    []string{"false"},
+			wantRan: true,
+			want:    StepFailed,
+			ignored: true,
+		},
+		{
+			name: "test",
+			env:  Env{"STEP_ID": "test", "BRANCH_IN": "main", "BRANCH_NAME": "feature"},
+			args: []string{"true"},
+			want: StepSkipped,
+		},
+		{
+			name:    "deploy",
+			env:     Env{"STEP_ID": "deploy", "RUN_IF_STEP": "test:skipped"},
+			args:    []string{"true"},
+			wantRan: true,
+			want:    StepRan,
+		},
+		{
+			name: "rollback-skipped-step-did-not-fail",
+			env:  Env{"STEP_ID": "rollback", "RUN_IF_STEP": "deploy:failed"},
+			args: []string{"true"},
+			want: StepSkipped,
+		},
+		{
+			name:    "notify-build-failed-and-deploy-ran",
+			env:     Env{"RUN_IF_STEP": "build:failed,deploy:ran"},
+			args:    []string{"true"},
+			wantRan: true,
+		},
+		{
+			name: "unknown-step-has-no-status",
+			env:  Env{"RUN_IF_STEP": "unknown:ran"},
+			args: []string{"true"},
+		},
+	}
+	for _, tt := range steps {
+		tt.env["STEP_STATE"] = state
+		c := newTestConfig(t, tt.env)
+		c.Dir = t.TempDir()
+		result, err := NewRunner(c, tt.env).Run(context.Background(), tt.args)
+		if err != nil {
+			t.Fatalf("Run() %s unexpected error: %v", tt.name, err)
+		}
+		if result.Ran != tt.wantRan {
+			t.Errorf("Run() %s ran = %t, want %t: %s", tt.name, result.Ran, tt.wantRan, result.Reason)
+		}
+		if tt.want == "" {
+			continue
+		}
+		recorded, err := readStepState(state)
+		if err != nil {
+			t.Fatalf("readStepState() unexpected error: %v", err)
+		}
+		rec := recorded[tt.env["STEP_ID"]]
+		if rec.Status != tt.want || rec.Ignored != tt.ignored {
+			t.Errorf("Run() %s recorded %+v, want status %q ignored %t", tt.name, rec, tt.want, tt.ignored)
+		}
+	}
+}
+
+func TestRunner_shouldRun_invalidStepCondition(t *testing.T) {
+	for _, cond := range []string{"build", "build:unknown", ":ran"} {
+		env := Env{"RUN_IF_STEP": cond, "STEP_STATE": filepath.Join(t.TempDir(), "steps.json")}
+		_, _, err := NewRunner(newTestConfig(t, env), env).shouldRun()
+		if err == nil {
+			t.Errorf("shouldRun() RUN_IF_STEP=%s should fail", cond)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright © 2019 gcp-config Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cbif
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Supported values for WORKSPACE_MODE.
+const (
+	WorkspaceModeSymlink = "symlink"
+	WorkspaceModeCopy    = "copy"
+	WorkspaceModeOverlay = "overlay"
+)
+
+// removeLinkTarget removes a previous symlink at path, if present. Any other
+// existing file or directory is an error, since removing it could lose data.
+func removeLinkTarget(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		return fmt.Errorf("refusing to remove existing non-symlink: %q", path)
+	}
+	return os.Remove(path)
+}
+
+// copyFile copies the regular file src to dst, preserving the mode and
+// modification time so that later mirrors can detect unchanged files.
+func copyFile(src, dst string, info os.FileInfo) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(dst, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
+// unchanged reports whether dst is the same kind of file as src with the same
+// size and modification time.
+func unchanged(src os.FileInfo, dst string) bool {
+	info, err := os.Lstat(dst)
+	if err != nil {
+		return false
+	}
+	return info.Mode() == src.Mode() && info.Size() == src.Size() && info.ModTime().Equal(src.ModTime())
+}
+
+// mirrorDir makes dst an exact copy of src. Only files that differ in size or
+// modification time are copied, and entries in dst not found in src are
+// removed.
+func mirrorDir(src, dst string) error {
+	seen := map[string]bool{}
+	err := filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		seen[rel] = true
+		target := filepath.Join(dst, rel)
+		switch {
+		case info.IsDir():
+			if t, err := os.Lstat(target); err == nil && !t.IsDir() {
+				if err := os.RemoveAll(target); err != nil {
+					return err
+				}
+			}
+			return os.MkdirAll(target, info.Mode().Perm())
+		case unchanged(info, target):
+			return nil
+		}
+		if err := os.RemoveAll(target); err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		}
+		if !info.Mode().IsRegular() {
+			log.Printf("Skipping irregular file: %q", p)
+			return nil
+		}
+		return copyFile(p, target, info)
+	})
+	if err != nil {
+		return err
+	}
+	// Remove entries from dst that are no longer in src.
+	return filepath.Walk(dst, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dst, p)
+		if err != nil || seen[rel] {
+			return err
+		}
+		if err := os.RemoveAll(p); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+}
+
+// isWithin reports whether path is dir or a descendant of dir.
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+// linkWorkspace makes the workspace available at absProjPath using the given
+// mode. The returned function completes the setup after commands have run,
+// e.g. by syncing changes back to the workspace.
+func linkWorkspace(mode, workspace, absProjPath string) (func() error, error) {
+	if err := os.MkdirAll(filepath.Dir(absProjPath), 0777); err != nil {
+		return nil, err
+	}
+	if err := removeLinkTarget(absProjPath); err != nil {
+		return nil, err
+	}
+	if mode != WorkspaceModeSymlink && isWithin(workspace, absProjPath) {
+		return nil, fmt.Errorf("%s mode requires %q to be outside of %q", mode, absProjPath, workspace)
+	}
+	switch mode {
+	case WorkspaceModeCopy:
+		if err := mirrorDir(workspace, absProjPath); err != nil {
+			return nil, err
+		}
+		log.Printf("SUCCESS! Copied workspace: %q -> %q", workspace, absProjPath)
+		return func() error {
+			log.Printf("Syncing changes back to workspace: %q -> %q", absProjPath, workspace)
+			return mirrorDir(absProjPath, workspace)
+		}, nil
+	case WorkspaceModeOverlay:
+		return mountOverlay(workspace, absProjPath)
+	default:
+		if err := os.Symlink(workspace, absProjPath); err != nil {
+			return nil, err
+		}
+		log.Printf("SUCCESS! Created symlink: ln -s %q %q", workspace, absProjPath)
+		return func() error { return nil }, nil
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright © 2019 gcp-config Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cbif
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// mountOverlay mounts an overlay filesystem at target with the workspace as
+// the read-only lower layer. Writes go to a temporary upper layer. The
+// returned function unmounts the overlay and only then applies the upper layer
+// to the workspace, since changing the lower layer of a mounted overlay is
+// undefined. Mounting requires CAP_SYS_ADMIN.
+func mountOverlay(workspace, target string) (func() error, error) {
+	tmp, err := os.MkdirTemp("", "cbif-overlay-")
+	if err != nil {
+		return nil, err
+	}
+	upper := filepath.Join(tmp, "upper")
+	work := filepath.Join(tmp, "work")
+	for _, dir := range []string{upper, work, target} {
+		if err := os.MkdirAll(dir, 0777); err != nil {
+			return nil, err
+		}
+	}
+	opts := fmt.Sprintf("lowerdir=%s,upperdir=%s,workdir=%s", workspace, upper, work)
+	if err := syscall.Mount("overlay", target, "overlay", 0, opts); err != nil {
+		os.RemoveAll(tmp)
+		return nil, fmt.Errorf("failed to mount overlay at %q: %w", target, err)
+	}
+	log.Printf("SUCCESS! Mounted overlay: %q -> %q", workspace, target)
+	return func() error {
+		if err := syscall.Unmount(target, 0); err != nil {
+			return fmt.Errorf("failed to unmount overlay at %q: %w", target, err)
+		}
+		log.Printf("Applying changes to workspace: %q -> %q", upper, workspace)
+		if err := applyUpper(upper, workspace); err != nil {
+			return err
+		}
+		return os.RemoveAll(tmp)
+	}, nil
+}
+
+// isWhiteout reports whether info is an overlay whiteout, i.e. a character
+// device with device number 0/0 that marks a deleted file or directory.
+func isWhiteout(info os.FileInfo) bool {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return ok && info.Mode()&os.ModeCharDevice != 0 && st.Rdev == 0
+}
+
+// isOpaque reports whether the upper directory dir replaces, rather than
+// merges with, the lower directory of the same name.
+func isOpaque(dir string) bool {
+	buf := make([]byte, 1)
+	for _, attr := range []string{"trusted.overlay.opaque", "user.overlay.opaque"} {
+		if n, err := syscall.Getxattr(dir, attr, buf); err == nil && n == 1 && buf[0] == 'y' {
+			return true
+		}
+	}
+	return false
+}
+
+// applyUpper applies the changes recorded in the upper layer of an unmounted
+// overlay to the lower directory. Changed files are copied, whiteouts are
+// removed from lower, and opaque directories replace their lower directory.
+func applyUpper(upper, lower string) error {
+	return filepath.Walk(upper, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(upper, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		target := filepath.Join(lower, rel)
+		switch {
+		case isWhiteout(info):
+			return os.RemoveAll(target)
+		case info.IsDir():
+			t, err := os.Lstat(target)
+			if err == nil && (!t.IsDir() || isOpaque(p)) {
+				if err := os.RemoveAll(target); err != nil {
+					return err
+				}
+			}
+			return os.MkdirAll(target, info.Mode().Perm())
+		}
+		if err := os.RemoveAll(target); err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		}
+		if !info.Mode().IsRegular() {
+			log.Printf("Skipping irregular file: %q", p)
+			return nil
+		}
+		return copyFile(p, target, info)
+	})
+}
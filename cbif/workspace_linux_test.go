@@ -0,0 +1,110 @@
+package cbif
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/m-lab/go/rtx"
+)
+
+func Test_applyUpper(t *testing.T) {
+	dir := t.TempDir()
+	upper := filepath.Join(dir, "upper")
+	lower := filepath.Join(dir, "lower")
+	writeFile(t, filepath.Join(lower, "keep.txt"), "keep")
+	writeFile(t, filepath.Join(lower, "modify.txt"), "original")
+	writeFile(t, filepath.Join(lower, "file-to-dir"), "file")
+	writeFile(t, filepath.Join(upper, "modify.txt"), "modified")
+	writeFile(t, filepath.Join(upper, "new/new.txt"), "new")
+	writeFile(t, filepath.Join(upper, "file-to-dir/x.txt"), "x")
+	rtx.Must(os.Symlink("keep.txt", filepath.Join(upper, "link")), "failed to create symlink")
+
+	rtx.Must(applyUpper(upper, lower), "failed to apply upper")
+
+	expected := map[string]string{
+		"keep.txt":          "keep",
+		"modify.txt":        "modified",
+		"new/new.txt":       "new",
+		"file-to-dir/x.txt": "x",
+		"link":              "keep",
+	}
+	for name, want := range expected {
+		if got := readFile(t, filepath.Join(lower, name)); got != want {
+			t.Errorf("applyUpper() %q = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func Test_applyUpper_whiteout(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("creating whiteouts requires root")
+	}
+	dir := t.TempDir()
+	upper := filepath.Join(dir, "upper")
+	lower := filepath.Join(dir, "lower")
+	writeFile(t, filepath.Join(lower, "remove.txt"), "remove")
+	writeFile(t, filepath.Join(lower, "remove-dir/a.txt"), "a")
+	rtx.Must(os.MkdirAll(upper, 0777), "failed to create dir")
+	for _, name := range []string{"remove.txt", "remove-dir"} {
+		rtx.Must(syscall.Mknod(filepath.Join(upper, name), syscall.S_IFCHR, 0), "failed to create whiteout")
+	}
+
+	rtx.Must(applyUpper(upper, lower), "failed to apply upper")
+
+	for _, name := range []string{"remove.txt", "remove-dir"} {
+		if _, err := os.Lstat(filepath.Join(lower, name)); !os.IsNotExist(err) {
+			t.Errorf("applyUpper() did not remove %q; err = %v", name, err)
+		}
+	}
+}
+
+func Test_linkWorkspace_overlay(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("mounting an overlay requires root")
+	}
+	dir := t.TempDir()
+	workspace := filepath.Join(dir, "workspace")
+	link := filepath.Join(dir, "go/src/github.com/m-lab/fake")
+	writeFile(t, filepath.Join(workspace, "keep.txt"), "keep")
+	writeFile(t, filepath.Join(workspace, "modify.txt"), "original")
+	writeFile(t, filepath.Join(workspace, "remove.txt"), "remove")
+	writeFile(t, filepath.Join(workspace, "remove/remove.txt"), "remove")
+
+	finish, err := linkWorkspace(WorkspaceModeOverlay, workspace, link)
+	if err != nil {
+		// E.g. unprivileged containers or filesystems without overlay support.
+		t.Skipf("overlay not available: %v", err)
+	}
+
+	// Simulate changes made by commands.
+	writeFile(t, filepath.Join(link, "modify.txt"), "modified content")
+	writeFile(t, filepath.Join(link, "new/new.txt"), "new")
+	rtx.Must(os.Remove(filepath.Join(link, "remove.txt")), "failed to remove file")
+	rtx.Must(os.RemoveAll(filepath.Join(link, "remove")), "failed to remove dir")
+	if got := readFile(t, filepath.Join(workspace, "modify.txt")); got != "original" {
+		t.Errorf("overlay modified the workspace before finish: %q", got)
+	}
+	rtx.Must(finish(), "failed to finish overlay")
+
+	expected := map[string]string{
+		"keep.txt":    "keep",
+		"modify.txt":  "modified content",
+		"new/new.txt": "new",
+	}
+	for name, want := range expected {
+		if got := readFile(t, filepath.Join(workspace, name)); got != want {
+			t.Errorf("overlay %q = %q, want %q", name, got, want)
+		}
+	}
+	for _, name := range []string{"remove.txt", "remove"} {
+		if _, err := os.Lstat(filepath.Join(workspace, name)); !os.IsNotExist(err) {
+			t.Errorf("overlay did not remove %q; err = %v", name, err)
+		}
+	}
+	// After unmounting, the mount point is an empty directory.
+	if entries, _ := os.ReadDir(link); len(entries) != 0 {
+		t.Errorf("overlay still mounted at %q", link)
+	}
+}
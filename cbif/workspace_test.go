@@ -0,0 +1,124 @@
+package cbif
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/m-lab/go/rtx"
+)
+
+func writeFile(t *testing.T, name, content string) {
+	rtx.Must(os.MkdirAll(filepath.Dir(name), 0777), "failed to create dir")
+	rtx.Must(os.WriteFile(name, []byte(content), 0666), "failed to write file")
+}
+
+func readFile(t *testing.T, name string) string {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Errorf("failed to read %q: %v", name, err)
+	}
+	return string(b)
+}
+
+func Test_linkWorkspace(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		existing func(link string)
+		wantErr  bool
+	}{
+		{
+			name: "symlink",
+			mode: WorkspaceModeSymlink,
+		},
+		{
+			name: "symlink-replaces-existing-symlink",
+			mode: WorkspaceModeSymlink,
+			existing: func(link string) {
+				rtx.Must(os.Symlink("/does-not-exist", link), "failed to create symlink")
+			},
+		},
+		{
+			name: "copy",
+			mode: WorkspaceModeCopy,
+		},
+		{
+			name: "error-refuse-to-remove-existing-directory",
+			mode: WorkspaceModeSymlink,
+			existing: func(link string) {
+				rtx.Must(os.MkdirAll(link, 0777), "failed to create dir")
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			workspace := filepath.Join(dir, "workspace")
+			link := filepath.Join(dir, "go/src/github.com/m-lab/fake")
+			writeFile(t, filepath.Join(workspace, "main.go"), "package main")
+			rtx.Must(os.MkdirAll(filepath.Dir(link), 0777), "failed to create dir")
+			if tt.existing != nil {
+				tt.existing(link)
+			}
+
+			finish, err := linkWorkspace(tt.mode, workspace, link)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("linkWorkspace() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := readFile(t, filepath.Join(link, "main.go")); got != "package main" {
+				t.Errorf("linkWorkspace() main.go = %q, want %q", got, "package main")
+			}
+			rtx.Must(finish(), "failed to finish workspace")
+		})
+	}
+}
+
+func Test_linkWorkspace_copySyncBack(t *testing.T) {
+	dir := t.TempDir()
+	workspace := filepath.Join(dir, "workspace")
+	link := filepath.Join(dir, "go/src/github.com/m-lab/fake")
+	writeFile(t, filepath.Join(workspace, "keep.txt"), "keep")
+	writeFile(t, filepath.Join(workspace, "modify.txt"), "original")
+	writeFile(t, filepath.Join(workspace, "remove/remove.txt"), "remove")
+
+	finish, err := linkWorkspace(WorkspaceModeCopy, workspace, link)
+	rtx.Must(err, "failed to copy workspace")
+	info, err := os.Lstat(link)
+	rtx.Must(err, "failed to stat link")
+	if !info.IsDir() {
+		t.Fatalf("linkWorkspace() copy mode did not create a real directory: %v", info.Mode())
+	}
+
+	// Simulate changes made by commands.
+	writeFile(t, filepath.Join(link, "modify.txt"), "modified content")
+	writeFile(t, filepath.Join(link, "new/new.txt"), "new")
+	rtx.Must(os.RemoveAll(filepath.Join(link, "remove")), "failed to remove dir")
+	rtx.Must(finish(), "failed to sync back")
+
+	expected := map[string]string{
+		"keep.txt":    "keep",
+		"modify.txt":  "modified content",
+		"new/new.txt": "new",
+	}
+	for name, want := range expected {
+		if got := readFile(t, filepath.Join(workspace, name)); got != want {
+			t.Errorf("sync back %q = %q, want %q", name, got, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "remove")); !os.IsNotExist(err) {
+		t.Errorf("sync back did not remove deleted directory; err = %v", err)
+	}
+}
+
+func Test_linkWorkspace_copyWithinWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	_, err := linkWorkspace(WorkspaceModeCopy, dir, filepath.Join(dir, "go/src/fake"))
+	if err == nil {
+		t.Errorf("linkWorkspace() copy into the workspace should fail")
+	}
+}
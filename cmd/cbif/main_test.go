@@ -2,29 +2,31 @@ package main
 
 import (
 	"flag"
-	"fmt"
-	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
 	"testing"
 
-	"github.com/m-lab/go/flagx"
+	"github.com/m-lab/gcp-config/cbif"
 	"github.com/m-lab/go/osx"
-	"github.com/m-lab/go/rtx"
-	"gopkg.in/m-lab/pipe.v3"
 )
 
+func TestMain(m *testing.M) {
+	// Commands with limits re-execute the test binary as the wrapper.
+	cbif.ExecLimitedIfRequested()
+	os.Exit(m.Run())
+}
+
 func Test_main(t *testing.T) {
-	// Create a tempdir as a fake /workspace and link target location.
-	tmpdir, err := ioutil.TempDir("", "maintesting-")
-	rtx.Must(err, "failed to create tempdir")
-	defer os.RemoveAll(tmpdir)
+	tmpdir := t.TempDir()
 
-	// Unpack the fake git data to use during tests.
-	b, err := pipe.CombinedOutput(
-		pipe.Exec("tar", "-C", tmpdir, "-xf", "../../testdata/fake.git.tar.gz"),
-	)
-	fmt.Println(string(b))
+	// Receive notifications from commands.
+	notified := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		notified++
+	}))
+	defer srv.Close()
 
 	tests := []struct {
 		name string
@@ -41,68 +43,79 @@ func Test_main(t *testing.T) {
 				"PROJECT_IN":  "correct-project",
 			},
 			args: []string{"fake-cbif", "echo"},
-			code: 0,
 		},
 		{
-			name: "command-does-not-run-wrong-branch",
+			name: "command-does-not-run-wrong-branch-flag",
 			env: map[string]string{
 				"BRANCH_NAME": "wrong-branch",
 			},
-			args: []string{"fake-cbif", "-branch-in=correct-branch", "echo"},
-			code: 0,
+			args: []string{"fake-cbif", "-branch-in=correct-branch", "false"},
+		},
+		{
+			name: "command-runs-and-exits-non-zero",
+			args: []string{"fake-cbif", "false"},
+			code: 1,
+		},
+		{
+			name: "command-exit-code-is-preserved",
+			args: []string{"fake-cbif", "-single-command", "sh", "-c", "exit 3"},
+			code: 3,
+		},
+		{
+			name: "ignore-errors-from-env",
+			env: map[string]string{
+				"IGNORE_ERRORS": "true",
+			},
+			args: []string{"fake-cbif", "false"},
 		},
 		{
-			name: "command-does-not-run-wrong-project",
+			name: "limits-from-env",
 			env: map[string]string{
-				"PROJECT_ID": "wrong-project",
+				"MAX_OPEN_FILES": "64",
 			},
-			args: []string{"fake-cbif", "-project-in=current-project", "echo"},
-			code: 0,
+			args: []string{"fake-cbif", "-single-command", "sh", "-c", "test $(ulimit -n) = 64"},
 		},
 		{
-			name: "command-runs-and-exists-non-zero",
+			name: "notify-on-failure",
+			env: map[string]string{
+				"NOTIFY_WEBHOOK": srv.URL,
+			},
 			args: []string{"fake-cbif", "false"},
 			code: 1,
 		},
 		{
-			name: "setup-workspace-link-run-success",
-			args: []string{"fake-cbif", "pwd"},
+			name: "log-dir-writes-command-output",
 			env: map[string]string{
-				"WORKSPACE":      tmpdir,
-				"WORKSPACE_LINK": path.Join(tmpdir, "go/this/is/a/path"),
+				"LOG_DIR": path.Join(tmpdir, "logs"),
 			},
+			args: []string{"fake-cbif", "echo hello"},
 		},
 		{
-			name: "setup-git-directory",
-			args: []string{"fake-cbif", "stat", ".git"},
+			name: "dry-run-does-not-run-command",
 			env: map[string]string{
-				"WORKSPACE":      tmpdir,
-				"WORKSPACE_LINK": path.Join(tmpdir, "go/this/is/a/path"),
-				"GIT_ORIGIN_URL": tmpdir + "/fake.git",
-				"COMMIT_SHA":     "2a5f2af7fad0af0e1a354f42660a78420cd4751f",
-				"SINGLE_COMMAND": "true",
+				"DRY_RUN": "true",
 			},
+			args: []string{"fake-cbif", "false"},
+		},
+		{
+			name: "explain-does-not-run-command",
+			args: []string{"fake-cbif", "explain", "false"},
 		},
 	}
 	for _, tt := range tests {
-		// Update os.Args for main's call to flag.Parse().
-		os.Args = tt.args
-
-		// Reset the other global flags.
-		projects = flagx.StringArray{}
-		branches = flagx.StringArray{}
-
-		// Completely reset command line flags.
-		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
-		setupFlags()
+		t.Run(tt.name, func(t *testing.T) {
+			// Update os.Args for main's call to flag.Parse().
+			os.Args = tt.args
 
-		// Save exit code.
-		code := 0
-		osExit = func(c int) {
-			code = c
-		}
+			// Completely reset command line flags.
+			flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+			setupFlags()
 
-		t.Run(tt.name, func(t *testing.T) {
+			// Save exit code.
+			code := 0
+			osExit = func(c int) {
+				code = c
+			}
 			for e, v := range tt.env {
 				d := osx.MustSetenv(e, v)
 				defer d()
@@ -115,4 +128,7 @@ func Test_main(t *testing.T) {
 			}
 		})
 	}
+	if notified != 1 {
+		t.Errorf("main() wrong number of notifications; got %d, want 1", notified)
+	}
 }
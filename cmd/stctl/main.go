@@ -25,13 +25,16 @@ import (
 
 	"github.com/m-lab/go/flagx"
 	"github.com/m-lab/go/pretty"
+	"github.com/m-lab/go/prometheusx"
 	"github.com/m-lab/go/rtx"
 
+	"google.golang.org/api/storage/v1"
 	"google.golang.org/api/storagetransfer/v1"
 )
 
 var (
 	project             string
+	jobKey              string
 	sourceBucket        string
 	destBucket          string
 	prefixes            flagx.StringArray
@@ -41,10 +44,24 @@ var (
 	minAge              time.Duration
 	maxAge              time.Duration
 	deleteAfterTransfer bool
+	configFile          string
+	prune               bool
+	confirm             bool
+	resolve             string
+	match               string
+	wait                bool
+	timeout             time.Duration
+	olderThan           = 90 * 24 * time.Hour
+	listen              string
+	refresh             time.Duration
+	format              string
+	sortBy              string
+	manifest            string
 )
 
 func init() {
 	flag.StringVar(&project, "project-id", "", "GCP project to sync transfer job.")
+	flag.StringVar(&jobKey, "key", "", "Optional job identity. Changes to source, target, or time update the same logical job.")
 	flag.StringVar(&sourceBucket, "gcs.source", "", "Source GCS bucket.")
 	flag.StringVar(&destBucket, "gcs.target", "", "Destination bucket.")
 	flag.Var(&prefixes, "include", "Only transfer files with given prefix. Default all prefixes. Can be specified multiple times.")
@@ -54,6 +71,23 @@ func init() {
 	flag.DurationVar(&minAge, "minFileAge", 0, "Minimum time since file modification")
 	flag.DurationVar(&maxAge, "maxFileAge", 0, "Maximum time since file modification")
 	flag.BoolVar(&deleteAfterTransfer, "deleteAfterTransfer", false, "Whether to delete source files after transfer")
+	flag.StringVar(&configFile, "f", "", "YAML file of transfer jobs to apply.")
+	flag.BoolVar(&prune, "prune", false, "Disable managed jobs that are not in the applied config.")
+	flag.BoolVar(&confirm, "confirm", false, "Confirm that -prune or doctor should disable jobs, or gc delete jobs. Default is a dry run.")
+	flag.StringVar(&resolve, "resolve", "", "Resolve duplicate jobs found by doctor, keeping the 'newest' job or the job matching the 'spec' in -f.")
+	flag.StringVar(&match, "match", "", "Run the single enabled job transferring <source>-><target> buckets.")
+	flag.BoolVar(&wait, "wait", false, "Wait for the started operation to finish.")
+	flag.DurationVar(&timeout, "timeout", 0, "Maximum time to wait for an operation to finish. Default no limit.")
+	flag.StringVar(&listen, "listen", ":9090", "Address for serve to export Prometheus metrics.")
+	flag.DurationVar(&refresh, "refresh", 5*time.Minute, "Interval between serve updates of transfer metrics.")
+	flag.StringVar(&format, "format", stctl.FormatTable, "Output format of list and operations: table, json, csv or yaml.")
+	flag.StringVar(&sortBy, "sort", "", "Sort list and operations by 'name' or 'start' time. Default API order.")
+	flag.StringVar(&manifest, "manifest", "", "GCS location for retry-failed to write the manifest of failed objects, gs://<bucket>/<object>.")
+	flag.Func("older-than", "Only gc disabled jobs neither run nor modified in this long, e.g. 90d or 36h. Default 90d.", func(s string) error {
+		var err error
+		olderThan, err = stctl.ParseAge(s)
+		return err
+	})
 }
 
 var usageText = `
@@ -61,19 +95,56 @@ NAME
   stctl - storage transfer control
 
 DESCRIPTION
-  stctl allows a user to create, disable, and list storage transfer jobs and
-  list past transfer operations for existing jobs.
+  stctl allows a user to create, disable, delete, run, and list storage
+  transfer jobs and list past transfer operations for existing jobs.
 
 EXAMPLES
   stctl -project-id <project> list
 
   stctl -project-id <project> operations <job name>
 
+  stctl -project-id <project> operations -format json -sort start <job name>
+
+  stctl -project-id <project> errors <operation name>
+
+  stctl -project-id <project> retry-failed -manifest gs://<bucket>/<object> <operation name>
+
   stctl -project-id <project> create -gcs.source <bucket> -gcs.target <bucket> \
 	-time <HH:MM:SS> -maxAge <duration> -minAge <duration> -deleteAfterTransfer true \
     -include ndt -include host -include neubot -include utilization
 
-  stctl -project-id <project> disable <job name>
+  stctl -project-id <project> disable|enable|delete <job name>
+
+  stctl -project-id <project> check
+
+  stctl -project-id <project> serve -listen :9090
+
+  stctl -project-id <project> gc -older-than 90d -confirm
+
+  stctl -project-id <project> apply -f transfers.yaml
+
+  stctl -project-id <project> apply -f transfers.yaml -prune -confirm
+
+  stctl -project-id <project> plan -f transfers.yaml
+
+  stctl -project-id <project> adopt -f transfers.yaml
+
+  stctl -project-id <project> doctor -resolve spec -f transfers.yaml -confirm
+
+  stctl -project-id <project> run -wait <job name>
+
+  stctl -project-id <project> run -match '<source>-><target>'
+
+  stctl -project-id <project> wait -timeout 2h <operation name>
+
+  stctl -project-id <project> pause|resume|cancel <operation name>
+
+  stctl -project-id <project> cancel-all <job name>
+
+  Plan exits with status 2 when changes are pending. Check exits with status 1
+  when any managed job failed, had failed objects, or has not succeeded within
+  two scheduled intervals. Wait and run -wait exit
+  with status 1 unless the operation succeeds before the timeout.
 
 USAGE
 `
@@ -94,17 +165,36 @@ func mustArg(n int) string {
 	return args[n]
 }
 
+func mustReadConfig(file string) *stctl.Config {
+	f, err := os.Open(file)
+	rtx.Must(err, "Failed to open config %q", file)
+	defer f.Close()
+	cfg, err := stctl.ReadConfig(f)
+	rtx.Must(err, "Failed to read config %q", file)
+	return cfg
+}
+
 func main() {
 	flag.Parse()
 	rtx.Must(flagx.ArgsFromEnv(flag.CommandLine), "Failed to parse flags")
 
+	op := mustArg(0)
+	// Allow flags to follow the command name, e.g. "apply -f transfers.yaml".
+	rtx.Must(flag.CommandLine.Parse(flag.Args()[1:]), "Failed to parse flags")
+
 	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 	service, err := storagetransfer.NewService(ctx)
 	rtx.Must(err, "Failed to create new storage transfer service")
 
 	cmd := &stctl.Command{
 		Client:              transfer.NewJob(project, service),
 		Project:             project,
+		Key:                 jobKey,
 		SourceBucket:        sourceBucket,
 		TargetBucket:        destBucket,
 		Prefixes:            prefixes,
@@ -115,9 +205,10 @@ func main() {
 		MaxFileAge:          maxAge.Truncate(time.Second),
 		DeleteAfterTransfer: deleteAfterTransfer,
 		Output:              os.Stdout,
+		Format:              format,
+		SortBy:              sortBy,
 	}
 
-	op := mustArg(0)
 	switch op {
 	case "create":
 		job, err := cmd.Create(ctx)
@@ -128,15 +219,107 @@ func main() {
 		rtx.Must(err, "Failed to sync")
 		pretty.Print(job)
 	case "disable":
-		name := mustArg(1)
+		name := mustArg(0)
 		job, err := cmd.Disable(ctx, name)
 		rtx.Must(err, "Failed to disable %q", name)
 		pretty.Print(job)
+	case "enable":
+		name := mustArg(0)
+		job, err := cmd.Enable(ctx, name)
+		rtx.Must(err, "Failed to enable %q", name)
+		pretty.Print(job)
+	case "delete":
+		name := mustArg(0)
+		job, err := cmd.Delete(ctx, name)
+		rtx.Must(err, "Failed to delete %q", name)
+		pretty.Print(job)
+	case "check":
+		_, err = cmd.Check(ctx)
+		rtx.Must(err, "Failed health check")
+	case "serve":
+		*prometheusx.ListenAddress = listen
+		srv := prometheusx.MustServeMetrics()
+		defer srv.Close()
+		rtx.Must(cmd.Serve(ctx, refresh), "Failed to serve metrics")
+	case "gc":
+		_, err = cmd.GC(ctx, olderThan, confirm)
+		rtx.Must(err, "Failed to gc disabled jobs")
 	case "list":
 		rtx.Must(cmd.ListJobs(ctx), "Failed to list jobs")
 	case "operations":
-		name := mustArg(1)
+		name := mustArg(0)
 		rtx.Must(cmd.ListOperations(ctx, name), "Failed to list operations for %q", name)
+	case "errors":
+		name := mustArg(0)
+		rtx.Must(cmd.Errors(ctx, name), "Failed to list errors for %q", name)
+	case "retry-failed":
+		name := mustArg(0)
+		storageService, err := storage.NewService(ctx)
+		rtx.Must(err, "Failed to create new storage service")
+		cmd.Objects = transfer.NewObjects(storageService)
+		job, err := cmd.RetryFailed(ctx, name, manifest)
+		rtx.Must(err, "Failed to retry failed objects of %q", name)
+		pretty.Print(job)
+	case "apply":
+		cfg := mustReadConfig(configFile)
+		_, err = cmd.Apply(ctx, cfg)
+		rtx.Must(err, "Failed to apply %q", configFile)
+		if prune {
+			_, err = cmd.Prune(ctx, cfg, confirm)
+			rtx.Must(err, "Failed to prune jobs not in %q", configFile)
+		}
+	case "adopt":
+		_, err = cmd.Adopt(ctx, mustReadConfig(configFile))
+		rtx.Must(err, "Failed to adopt jobs in %q", configFile)
+	case "plan":
+		plans := []*stctl.Plan{}
+		if configFile != "" {
+			plans, err = cmd.PlanConfig(ctx, mustReadConfig(configFile))
+			rtx.Must(err, "Failed to plan %q", configFile)
+		} else {
+			p, err := cmd.Plan(ctx)
+			rtx.Must(err, "Failed to plan")
+			plans = append(plans, p)
+		}
+		for _, p := range plans {
+			if p.Pending() {
+				os.Exit(2)
+			}
+		}
+	case "doctor":
+		var cfg *stctl.Config
+		if configFile != "" {
+			cfg = mustReadConfig(configFile)
+		}
+		_, err = cmd.Doctor(ctx, cfg, resolve, confirm)
+		rtx.Must(err, "Failed to check for duplicate jobs")
+	case "run":
+		var name string
+		if match != "" {
+			name, err = cmd.Match(ctx, match)
+			rtx.Must(err, "Failed to match job %q", match)
+		} else {
+			name = mustArg(0)
+		}
+		_, err = cmd.Run(ctx, name, wait)
+		rtx.Must(err, "Failed to run %q", name)
+	case "wait":
+		name := mustArg(0)
+		_, err = cmd.Wait(ctx, name)
+		rtx.Must(err, "Failed to wait for %q", name)
+	case "pause":
+		name := mustArg(0)
+		rtx.Must(cmd.Pause(ctx, name), "Failed to pause %q", name)
+	case "resume":
+		name := mustArg(0)
+		rtx.Must(cmd.Resume(ctx, name), "Failed to resume %q", name)
+	case "cancel":
+		name := mustArg(0)
+		rtx.Must(cmd.Cancel(ctx, name), "Failed to cancel %q", name)
+	case "cancel-all":
+		name := mustArg(0)
+		_, err = cmd.CancelAll(ctx, name)
+		rtx.Must(err, "Failed to cancel operations of %q", name)
 	default:
 		flag.Usage()
 	}
@@ -0,0 +1,72 @@
+package stctl
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/m-lab/go/logx"
+	"github.com/stephen-soltesz/pretty"
+
+	"google.golang.org/api/storagetransfer/v1"
+)
+
+// Adopt migrates existing jobs identified by description to the keys given in
+// cfg. For every keyed job selected for c.Project without an existing keyed
+// job, Adopt updates the description of the job matching the legacy
+// description, so that later syncs update that job rather than create a new
+// one. Adopt returns the adopted jobs.
+func (c *Command) Adopt(ctx context.Context, cfg *Config) ([]*storagetransfer.TransferJob, error) {
+	byIdentity := map[string]*storagetransfer.TransferJob{}
+	visit := func(resp *storagetransfer.ListTransferJobsResponse) error {
+		for _, job := range resp.TransferJobs {
+			byIdentity[jobIdentity(job.Description)] = job
+		}
+		return nil
+	}
+	if err := c.Client.Jobs(ctx, visit); err != nil {
+		return nil, err
+	}
+	adopted := []*storagetransfer.TransferJob{}
+	for i := range cfg.Jobs {
+		j := &cfg.Jobs[i]
+		if j.Key == "" || !j.selected(c.Project) {
+			continue
+		}
+		jc, err := c.forJob(j)
+		if err != nil {
+			return nil, err
+		}
+		if job, ok := byIdentity[jc.identity()]; ok {
+			fmt.Fprintf(c.Output, "%-9s %-25s key:%s\n", "keyed", job.Name, jc.Key)
+			continue
+		}
+		legacy := getDesc(jc.SourceBucket, jc.TargetBucket, jc.StartTime)
+		current, ok := byIdentity[legacy]
+		if !ok {
+			fmt.Fprintf(c.Output, "%-9s %-25s key:%s desc:%q\n", "not-found", "", jc.Key, legacy)
+			continue
+		}
+		job, err := jc.adopt(ctx, current)
+		if err != nil {
+			return nil, fmt.Errorf("failed to adopt %q: %w", current.Name, err)
+		}
+		adopted = append(adopted, job)
+		fmt.Fprintf(c.Output, "%-9s %-25s key:%s\n", "adopted", current.Name, jc.Key)
+	}
+	return adopted, nil
+}
+
+// adopt updates the description of the current job to include c.Key.
+func (c *Command) adopt(ctx context.Context, current *storagetransfer.TransferJob) (*storagetransfer.TransferJob, error) {
+	update := &storagetransfer.UpdateTransferJobRequest{
+		ProjectId: c.Project,
+		// MUST: only set three fields: `Description`, `TransferSpec`, and `Status`.
+		TransferJob: &storagetransfer.TransferJob{
+			Description:  c.description(),
+			TransferSpec: current.TransferSpec,
+			Status:       current.Status,
+		},
+	}
+	logx.Debug.Print(pretty.Sprint(update))
+	return c.Client.Update(ctx, current.Name, update)
+}
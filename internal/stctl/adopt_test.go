@@ -0,0 +1,232 @@
+package stctl_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/m-lab/gcp-config/internal/stctl"
+	"github.com/m-lab/go/flagx"
+	storagetransfer "google.golang.org/api/storagetransfer/v1"
+)
+
+var getKeyDesc = stctl.GetKeyDesc
+
+func TestCommand_Adopt(t *testing.T) {
+	start := flagx.Time{Hour: 1, Minute: 2, Second: 3}
+	legacy := func() *storagetransfer.TransferJob {
+		return &storagetransfer.TransferJob{
+			Name:        "transferJobs/legacy",
+			Description: getDesc("legacy", "fake-target", start),
+			Status:      "ENABLED",
+		}
+	}
+	cfg := &stctl.Config{
+		Jobs: []stctl.JobConfig{
+			{Key: "legacy", Source: "legacy", Target: "fake-target", Time: "01:02:03"},
+			{Key: "keyed", Source: "keyed", Target: "fake-target", Time: "01:02:03"},
+			{Key: "missing", Source: "missing", Target: "fake-target", Time: "01:02:03"},
+			{Source: "unkeyed", Target: "fake-target", Time: "01:02:03"},
+		},
+	}
+	jobs := func() *storagetransfer.ListTransferJobsResponse {
+		return &storagetransfer.ListTransferJobsResponse{
+			TransferJobs: []*storagetransfer.TransferJob{
+				legacy(),
+				{Name: "transferJobs/keyed", Description: getKeyDesc("keyed", "keyed", "fake-target", start)},
+				{Name: "transferJobs/unkeyed", Description: getDesc("unkeyed", "fake-target", start)},
+			},
+		}
+	}
+	tests := []struct {
+		name    string
+		client  *fakeTJ
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "success",
+			client: &fakeTJ{listJobResp: jobs(), job: legacy()},
+			want:   []string{getKeyDesc("legacy", "legacy", "fake-target", start)},
+		},
+		{
+			name:    "error-update",
+			client:  &fakeTJ{listJobResp: jobs(), updateErr: errors.New("fake update error")},
+			wantErr: true,
+		},
+		{
+			name:    "error-list",
+			client:  &fakeTJ{listErr: errors.New("fake list error")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Command{Client: tt.client, Output: &bytes.Buffer{}}
+			got, err := c.Adopt(context.Background(), cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Command.Adopt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			descs := []string{}
+			for _, job := range got {
+				descs = append(descs, job.Description)
+			}
+			if diff := deep.Equal(descs, tt.want); diff != nil {
+				t.Errorf("Command.Adopt() did not match expected;\n%s", strings.Join(diff, "\n"))
+			}
+		})
+	}
+}
+
+func TestCommand_Sync_keyed(t *testing.T) {
+	start := flagx.Time{Hour: 1, Minute: 2, Second: 3}
+	existing := func() *storagetransfer.TransferJob {
+		return &storagetransfer.TransferJob{
+			Name:        "transferJobs/keyed",
+			Description: getKeyDesc("archive", "fake-source", "fake-target", start),
+			Schedule: &storagetransfer.Schedule{
+				StartTimeOfDay: &storagetransfer.TimeOfDay{Hours: 1, Minutes: 2, Seconds: 3},
+			},
+			TransferSpec: &storagetransfer.TransferSpec{
+				GcsDataSource: &storagetransfer.GcsData{BucketName: "fake-source"},
+				GcsDataSink:   &storagetransfer.GcsData{BucketName: "fake-target"},
+			},
+		}
+	}
+	tests := []struct {
+		name     string
+		c        *Command
+		wantName string
+		wantDesc string
+	}{
+		{
+			name: "unchanged",
+			c: &Command{
+				Key:          "archive",
+				SourceBucket: "fake-source",
+				TargetBucket: "fake-target",
+				StartTime:    start,
+			},
+			wantName: "transferJobs/keyed",
+			wantDesc: getKeyDesc("archive", "fake-source", "fake-target", start),
+		},
+		{
+			name: "update-source-in-place",
+			c: &Command{
+				Key:          "archive",
+				SourceBucket: "new-source",
+				TargetBucket: "fake-target",
+				StartTime:    start,
+			},
+			wantName: "transferJobs/keyed",
+			wantDesc: getKeyDesc("archive", "new-source", "fake-target", start),
+		},
+		{
+			name: "replace-start-time",
+			c: &Command{
+				Key:          "archive",
+				SourceBucket: "fake-source",
+				TargetBucket: "fake-target",
+				StartTime:    flagx.Time{Hour: 3},
+			},
+			wantName: "THIS-IS-A-FAKE-ASSIGNED-JOB-NAME",
+			wantDesc: getKeyDesc("archive", "fake-source", "fake-target", flagx.Time{Hour: 3}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.c.Client = &fakeTJ{
+				listJobResp: &storagetransfer.ListTransferJobsResponse{
+					TransferJobs: []*storagetransfer.TransferJob{existing()},
+				},
+				job: existing(),
+			}
+			job, err := tt.c.Sync(context.Background())
+			if err != nil {
+				t.Fatalf("Command.Sync() unexpected error = %v", err)
+			}
+			if job.Name != tt.wantName || job.Description != tt.wantDesc {
+				t.Errorf("Command.Sync() = %q %q, want %q %q", job.Name, job.Description, tt.wantName, tt.wantDesc)
+			}
+		})
+	}
+}
+
+func TestCommand_Sync_adoptsLegacyJob(t *testing.T) {
+	start := flagx.Time{Hour: 1, Minute: 2, Second: 3}
+	job := func(name, desc string) *storagetransfer.TransferJob {
+		return &storagetransfer.TransferJob{
+			Name:        name,
+			Description: desc,
+			Schedule: &storagetransfer.Schedule{
+				StartTimeOfDay: &storagetransfer.TimeOfDay{Hours: 1, Minutes: 2, Seconds: 3},
+			},
+			TransferSpec: &storagetransfer.TransferSpec{
+				GcsDataSource: &storagetransfer.GcsData{BucketName: "fake-source"},
+				GcsDataSink:   &storagetransfer.GcsData{BucketName: "fake-target"},
+			},
+		}
+	}
+	legacy := func() *storagetransfer.TransferJob {
+		return job("transferJobs/legacy", getDesc("fake-source", "fake-target", start))
+	}
+	tests := []struct {
+		name     string
+		jobs     []*storagetransfer.TransferJob
+		wantName string
+		wantPlan stctl.PlanAction
+	}{
+		{
+			name:     "adopt-legacy",
+			jobs:     []*storagetransfer.TransferJob{legacy()},
+			wantName: "transferJobs/legacy",
+			wantPlan: stctl.PlanUpdate,
+		},
+		{
+			name: "prefer-keyed",
+			jobs: []*storagetransfer.TransferJob{
+				legacy(),
+				job("transferJobs/keyed", getKeyDesc("archive", "fake-source", "fake-target", start)),
+			},
+			wantName: "transferJobs/keyed",
+			wantPlan: stctl.PlanNoop,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Command{
+				Key:          "archive",
+				SourceBucket: "fake-source",
+				TargetBucket: "fake-target",
+				StartTime:    start,
+				Output:       &bytes.Buffer{},
+				Client: &fakeTJ{
+					listJobResp: &storagetransfer.ListTransferJobsResponse{TransferJobs: tt.jobs},
+					job:         legacy(),
+					createErr:   errors.New("fake create error; sync must not create a duplicate"),
+				},
+			}
+			p, err := c.Plan(context.Background())
+			if err != nil {
+				t.Fatalf("Command.Plan() unexpected error = %v", err)
+			}
+			if p.Name != tt.wantName || p.Action != tt.wantPlan {
+				t.Errorf("Command.Plan() = %q %q, want %q %q", p.Name, p.Action, tt.wantName, tt.wantPlan)
+			}
+			got, err := c.Sync(context.Background())
+			if err != nil {
+				t.Fatalf("Command.Sync() unexpected error = %v", err)
+			}
+			wantDesc := getKeyDesc("archive", "fake-source", "fake-target", start)
+			if got.Name != tt.wantName || got.Description != wantDesc {
+				t.Errorf("Command.Sync() = %q %q, want %q %q", got.Name, got.Description, tt.wantName, wantDesc)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package stctl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/m-lab/go/flagx"
+	"github.com/m-lab/go/logx"
+
+	"google.golang.org/api/storagetransfer/v1"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is a declarative list of transfer jobs.
+type Config struct {
+	Jobs []JobConfig `yaml:"jobs"`
+}
+
+// JobConfig describes a single transfer job. Field names match the
+// corresponding stctl flags.
+type JobConfig struct {
+	// Key optionally identifies the job, so that changes to the source,
+	// target, or start time update the same logical job.
+	Key                 string        `yaml:"key"`
+	Source              string        `yaml:"source"`
+	Target              string        `yaml:"target"`
+	Time                string        `yaml:"time"`
+	Interval            string        `yaml:"interval"`
+	Include             []string      `yaml:"include"`
+	MinFileAge          time.Duration `yaml:"minFileAge"`
+	MaxFileAge          time.Duration `yaml:"maxFileAge"`
+	DeleteAfterTransfer bool          `yaml:"deleteAfterTransfer"`
+	// Projects restricts the job to the named projects. Default all projects.
+	Projects []string `yaml:"projects"`
+}
+
+// ReadConfig reads and validates a YAML transfer job configuration.
+func ReadConfig(r io.Reader) (*Config, error) {
+	cfg := &Config{}
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && err != io.EOF {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for i := range cfg.Jobs {
+		j := &cfg.Jobs[i]
+		if j.Source == "" || j.Target == "" {
+			return nil, fmt.Errorf("job %d: source and target are required", i)
+		}
+		if j.Key != "" && !validKey(j.Key) {
+			return nil, fmt.Errorf("job %d: invalid key %q", i, j.Key)
+		}
+		jc, err := (&Command{}).forJob(j)
+		if err != nil {
+			return nil, fmt.Errorf("job %d: invalid time %q: %w", i, j.Time, err)
+		}
+		// Jobs are identified by key or description, so every job must be unique.
+		id := jc.identity()
+		for _, p := range j.projectsOrAll() {
+			if seen[p+id] {
+				return nil, fmt.Errorf("job %d: duplicate job %q", i, id)
+			}
+			seen[p+id] = true
+		}
+	}
+	return cfg, nil
+}
+
+func (j *JobConfig) startTime() (flagx.Time, error) {
+	t := flagx.Time{}
+	err := t.Set(j.Time)
+	return t, err
+}
+
+func (j *JobConfig) projectsOrAll() []string {
+	if len(j.Projects) == 0 {
+		return []string{""}
+	}
+	return j.Projects
+}
+
+// selected reports whether the job applies to the given project.
+func (j *JobConfig) selected(project string) bool {
+	if len(j.Projects) == 0 {
+		return true
+	}
+	for _, p := range j.Projects {
+		if p == project {
+			return true
+		}
+	}
+	return false
+}
+
+// forJob returns a copy of c with the job parameters of j.
+func (c *Command) forJob(j *JobConfig) (*Command, error) {
+	start, err := j.startTime()
+	if err != nil {
+		return nil, err
+	}
+	jc := *c
+	jc.Key = j.Key
+	jc.SourceBucket = j.Source
+	jc.TargetBucket = j.Target
+	jc.StartTime = start
+	jc.Interval = j.Interval
+	jc.Prefixes = j.Include
+	jc.MinFileAge = j.MinFileAge.Truncate(time.Second)
+	jc.MaxFileAge = j.MaxFileAge.Truncate(time.Second)
+	jc.DeleteAfterTransfer = j.DeleteAfterTransfer
+	return &jc, nil
+}
+
+// ApplyResult describes the action taken for a single configured job.
+type ApplyResult struct {
+	Name        string
+	Description string
+	Action      SyncAction
+}
+
+// Apply syncs every job in cfg selected for c.Project and writes a summary of
+// the actions taken to c.Output.
+func (c *Command) Apply(ctx context.Context, cfg *Config) ([]ApplyResult, error) {
+	results := []ApplyResult{}
+	counts := map[SyncAction]int{}
+	for i := range cfg.Jobs {
+		j := &cfg.Jobs[i]
+		if !j.selected(c.Project) {
+			logx.Debug.Println("Skipping job for other projects:", j.Source, j.Target, j.Projects)
+			continue
+		}
+		jc, err := c.forJob(j)
+		if err != nil {
+			return results, err
+		}
+		desc := jc.description()
+		job, action, err := jc.sync(ctx)
+		if err != nil {
+			return results, fmt.Errorf("failed to sync %q: %w", desc, err)
+		}
+		results = append(results, ApplyResult{Name: job.Name, Description: desc, Action: action})
+		counts[action]++
+		fmt.Fprintf(c.Output, "%-9s %-25s desc:%q\n", action, job.Name, desc)
+	}
+	fmt.Fprintf(c.Output, "unchanged:%d updated:%d replaced:%d created:%d\n",
+		counts[ActionUnchanged], counts[ActionUpdated], counts[ActionReplaced], counts[ActionCreated])
+	return results, nil
+}
+
+// Prune disables enabled jobs managed by stctl that are not in cfg for
+// c.Project. Unless confirm is true, Prune only reports the jobs it would
+// disable.
+func (c *Command) Prune(ctx context.Context, cfg *Config, confirm bool) ([]*storagetransfer.TransferJob, error) {
+	desired := map[string]bool{}
+	for i := range cfg.Jobs {
+		j := &cfg.Jobs[i]
+		if !j.selected(c.Project) {
+			continue
+		}
+		jc, err := c.forJob(j)
+		if err != nil {
+			return nil, err
+		}
+		desired[jc.identity()] = true
+	}
+	prune := []*storagetransfer.TransferJob{}
+	managed := 0
+	visit := func(resp *storagetransfer.ListTransferJobsResponse) error {
+		for _, job := range resp.TransferJobs {
+			if !strings.HasPrefix(job.Description, managedPrefix) {
+				continue
+			}
+			managed++
+			if !desired[jobIdentity(job.Description)] {
+				prune = append(prune, job)
+			}
+		}
+		return nil
+	}
+	if err := c.Client.Jobs(ctx, visit); err != nil {
+		return nil, err
+	}
+	if len(desired) == 0 && managed > 0 {
+		// Most likely the wrong config or project rather than an intent to
+		// disable every job.
+		return nil, fmt.Errorf("refusing to prune all %d managed jobs: no jobs configured for %q", managed, c.Project)
+	}
+	for _, job := range prune {
+		if !confirm {
+			fmt.Fprintf(c.Output, "would disable %-25s desc:%q\n", job.Name, job.Description)
+			continue
+		}
+		if _, err := c.Disable(ctx, job.Name); err != nil {
+			return nil, fmt.Errorf("failed to disable %q: %w", job.Name, err)
+		}
+		fmt.Fprintf(c.Output, "disabled %-25s desc:%q\n", job.Name, job.Description)
+	}
+	if !confirm {
+		fmt.Fprintf(c.Output, "dry run: would prune:%d; use -confirm to disable\n", len(prune))
+		return prune, nil
+	}
+	fmt.Fprintf(c.Output, "pruned:%d\n", len(prune))
+	return prune, nil
+}
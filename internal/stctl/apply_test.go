@@ -0,0 +1,278 @@
+package stctl_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+	"github.com/m-lab/gcp-config/internal/stctl"
+	"github.com/m-lab/go/flagx"
+	storagetransfer "google.golang.org/api/storagetransfer/v1"
+)
+
+func TestReadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		want    *stctl.Config
+		wantErr bool
+	}{
+		{
+			name: "success",
+			config: `
+jobs:
+- source: fake-source
+  target: fake-target
+  time: "01:02:03"
+  interval: 3600s
+  include: [a, b]
+  minFileAge: 1h
+  maxFileAge: 120h
+  deleteAfterTransfer: true
+  projects: [mlab-sandbox]
+`,
+			want: &stctl.Config{
+				Jobs: []stctl.JobConfig{
+					{
+						Source:              "fake-source",
+						Target:              "fake-target",
+						Time:                "01:02:03",
+						Interval:            "3600s",
+						Include:             []string{"a", "b"},
+						MinFileAge:          time.Hour,
+						MaxFileAge:          120 * time.Hour,
+						DeleteAfterTransfer: true,
+						Projects:            []string{"mlab-sandbox"},
+					},
+				},
+			},
+		},
+		{
+			name:   "success-empty",
+			config: "",
+			want:   &stctl.Config{},
+		},
+		{
+			name: "success-same-job-different-projects",
+			config: `
+jobs:
+- {source: a, target: b, time: "01:00:00", projects: [mlab-sandbox]}
+- {source: a, target: b, time: "01:00:00", projects: [mlab-staging]}
+`,
+			want: &stctl.Config{
+				Jobs: []stctl.JobConfig{
+					{Source: "a", Target: "b", Time: "01:00:00", Projects: []string{"mlab-sandbox"}},
+					{Source: "a", Target: "b", Time: "01:00:00", Projects: []string{"mlab-staging"}},
+				},
+			},
+		},
+		{
+			name:    "error-unknown-field",
+			config:  "jobs:\n- {source: a, target: b, time: \"01:00:00\", prefixes: [a]}\n",
+			wantErr: true,
+		},
+		{
+			name:    "error-missing-target",
+			config:  "jobs:\n- {source: a, time: \"01:00:00\"}\n",
+			wantErr: true,
+		},
+		{
+			name:    "error-bad-time",
+			config:  "jobs:\n- {source: a, target: b, time: \"1 o'clock\"}\n",
+			wantErr: true,
+		},
+		{
+			name:    "error-duplicate-job",
+			config:  "jobs:\n- {source: a, target: b, time: \"01:00:00\"}\n- {source: a, target: b, time: \"01:00:00\", include: [x]}\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := stctl.ReadConfig(strings.NewReader(tt.config))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := deep.Equal(got, tt.want); !tt.wantErr && diff != nil {
+				t.Errorf("ReadConfig() did not match expected;\n%s", strings.Join(diff, "\n"))
+			}
+		})
+	}
+}
+
+func TestCommand_Apply(t *testing.T) {
+	start := flagx.Time{Hour: 1, Minute: 2, Second: 3}
+	existing := func(src string, prefixes ...string) *storagetransfer.TransferJob {
+		return &storagetransfer.TransferJob{
+			Name:        "transferJobs/" + src,
+			Description: getDesc(src, "fake-target", start),
+			Schedule: &storagetransfer.Schedule{
+				StartTimeOfDay: &storagetransfer.TimeOfDay{Hours: 1, Minutes: 2, Seconds: 3},
+			},
+			TransferSpec: &storagetransfer.TransferSpec{
+				GcsDataSource:    &storagetransfer.GcsData{BucketName: src},
+				GcsDataSink:      &storagetransfer.GcsData{BucketName: "fake-target"},
+				ObjectConditions: &storagetransfer.ObjectConditions{IncludePrefixes: prefixes},
+			},
+		}
+	}
+	cfg := &stctl.Config{
+		Jobs: []stctl.JobConfig{
+			{Source: "unchanged", Target: "fake-target", Time: "01:02:03", Include: []string{"a"}},
+			{Source: "updated", Target: "fake-target", Time: "01:02:03", Include: []string{"a", "b"}},
+			{Source: "replaced", Target: "fake-target", Time: "01:02:03", Interval: "3600s", Include: []string{"a"}},
+			{Source: "created", Target: "fake-target", Time: "01:02:03"},
+			{Source: "other-project", Target: "fake-target", Time: "01:02:03", Projects: []string{"mlab-oti"}},
+		},
+	}
+	tests := []struct {
+		name    string
+		client  *fakeTJ
+		want    []stctl.ApplyResult
+		summary string
+		wantErr bool
+	}{
+		{
+			name: "success",
+			client: &fakeTJ{
+				listJobResp: &storagetransfer.ListTransferJobsResponse{
+					TransferJobs: []*storagetransfer.TransferJob{
+						existing("unchanged", "a"),
+						existing("updated", "a"),
+						existing("replaced", "a"),
+					},
+				},
+				job: existing("updated", "a"),
+			},
+			want: []stctl.ApplyResult{
+				{Name: "transferJobs/unchanged", Description: getDesc("unchanged", "fake-target", start), Action: stctl.ActionUnchanged},
+				{Name: "transferJobs/updated", Description: getDesc("updated", "fake-target", start), Action: stctl.ActionUpdated},
+				{Name: "THIS-IS-A-FAKE-ASSIGNED-JOB-NAME", Description: getDesc("replaced", "fake-target", start), Action: stctl.ActionReplaced},
+				{Name: "THIS-IS-A-FAKE-ASSIGNED-JOB-NAME", Description: getDesc("created", "fake-target", start), Action: stctl.ActionCreated},
+			},
+			summary: "unchanged:1 updated:1 replaced:1 created:1\n",
+		},
+		{
+			name: "error-create",
+			client: &fakeTJ{
+				listJobResp: &storagetransfer.ListTransferJobsResponse{},
+				createErr:   errors.New("fake create error"),
+			},
+			want:    []stctl.ApplyResult{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := &bytes.Buffer{}
+			c := &Command{Client: tt.client, Project: "mlab-sandbox", Output: output}
+			got, err := c.Apply(context.Background(), cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Command.Apply() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := deep.Equal(got, tt.want); diff != nil {
+				t.Errorf("Command.Apply() did not match expected;\n%s", strings.Join(diff, "\n"))
+			}
+			if !strings.HasSuffix(output.String(), tt.summary) {
+				t.Errorf("Command.Apply() wrong summary; got %q, want %q", output.String(), tt.summary)
+			}
+		})
+	}
+}
+
+func TestCommand_Prune(t *testing.T) {
+	start := flagx.Time{Hour: 1, Minute: 2, Second: 3}
+	managed := func(src string) *storagetransfer.TransferJob {
+		return &storagetransfer.TransferJob{
+			Name:         "transferJobs/" + src,
+			Description:  getDesc(src, "fake-target", start),
+			Status:       "ENABLED",
+			TransferSpec: &storagetransfer.TransferSpec{},
+		}
+	}
+	cfg := &stctl.Config{
+		Jobs: []stctl.JobConfig{
+			{Source: "keep", Target: "fake-target", Time: "01:02:03"},
+			{Source: "other-project", Target: "fake-target", Time: "01:02:03", Projects: []string{"mlab-oti"}},
+		},
+	}
+	jobs := &storagetransfer.ListTransferJobsResponse{
+		TransferJobs: []*storagetransfer.TransferJob{
+			managed("keep"),
+			managed("removed"),
+			managed("other-project"),
+			{Name: "transferJobs/unmanaged", Description: "created by hand"},
+		},
+	}
+	tests := []struct {
+		name       string
+		cfg        *stctl.Config
+		confirm    bool
+		client     *fakeTJ
+		want       []string
+		wantStatus string
+		wantErr    bool
+	}{
+		{
+			name:       "success-dry-run",
+			cfg:        cfg,
+			client:     &fakeTJ{listJobResp: jobs, job: managed("removed")},
+			want:       []string{"transferJobs/removed", "transferJobs/other-project"},
+			wantStatus: "ENABLED",
+		},
+		{
+			name:       "success-confirm",
+			cfg:        cfg,
+			confirm:    true,
+			client:     &fakeTJ{listJobResp: jobs, job: managed("removed")},
+			want:       []string{"transferJobs/removed", "transferJobs/other-project"},
+			wantStatus: "DISABLED",
+		},
+		{
+			name:    "error-refuse-to-prune-all",
+			cfg:     &stctl.Config{},
+			confirm: true,
+			client:  &fakeTJ{listJobResp: jobs, job: managed("removed")},
+			wantErr: true,
+		},
+		{
+			name:    "error-disable",
+			cfg:     cfg,
+			confirm: true,
+			client:  &fakeTJ{listJobResp: jobs, getErr: errors.New("fake get error")},
+			wantErr: true,
+		},
+		{
+			name:    "error-list",
+			cfg:     cfg,
+			client:  &fakeTJ{listErr: errors.New("fake list error")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Command{Client: tt.client, Project: "mlab-sandbox", Output: &bytes.Buffer{}}
+			got, err := c.Prune(context.Background(), tt.cfg, tt.confirm)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Command.Prune() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			names := []string{}
+			for _, job := range got {
+				names = append(names, job.Name)
+			}
+			if diff := deep.Equal(names, tt.want); diff != nil {
+				t.Errorf("Command.Prune() did not match expected;\n%s", strings.Join(diff, "\n"))
+			}
+			if tt.client.job.Status != tt.wantStatus {
+				t.Errorf("Command.Prune() wrong status; got %q, want %q", tt.client.job.Status, tt.wantStatus)
+			}
+		})
+	}
+}
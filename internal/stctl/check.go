@@ -0,0 +1,159 @@
+package stctl
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/api/storagetransfer/v1"
+)
+
+// Health describes the state of a managed job reported by Check.
+type Health string
+
+// Health states reported by Check.
+const (
+	HealthOK     = Health("ok")
+	HealthNew    = Health("new")
+	HealthFailed = Health("failed")
+	HealthErrors = Health("errors")
+	HealthStale  = Health("stale")
+)
+
+// JobHealth summarizes the recent operations of a managed job.
+type JobHealth struct {
+	Name        string
+	Description string
+	Health      Health
+	// LastStatus is the status of the most recent finished operation.
+	LastStatus string
+	// LastSuccess is the start time of the most recent successful operation.
+	LastSuccess time.Time
+	// Window is the longest expected time between successful operations.
+	Window time.Duration
+}
+
+// Healthy reports whether the job needs no attention.
+func (h *JobHealth) Healthy() bool {
+	return h.Health == HealthOK || h.Health == HealthNew
+}
+
+// window returns the longest expected time between successful operations of
+// job, i.e. two scheduled intervals, so that one operation may still be
+// running or be late without alerting. Jobs without an interval run daily.
+func window(job *storagetransfer.TransferJob) time.Duration {
+	interval := 24 * time.Hour
+	if job.Schedule != nil && job.Schedule.RepeatInterval != "" {
+		if d, err := time.ParseDuration(job.Schedule.RepeatInterval); err == nil && d > 0 {
+			interval = d
+		}
+	}
+	return 2 * interval
+}
+
+// Check inspects the operations of every enabled job managed by stctl and
+// prints a status line per job. A job is unhealthy if its last finished
+// operation failed, if that operation failed to transfer any objects, or if
+// no operation succeeded within the window expected from its schedule. Check
+// returns an error if any job is unhealthy.
+func (c *Command) Check(ctx context.Context) ([]*JobHealth, error) {
+	jobs := []*storagetransfer.TransferJob{}
+	visit := func(resp *storagetransfer.ListTransferJobsResponse) error {
+		for _, job := range resp.TransferJobs {
+			if strings.HasPrefix(job.Description, managedPrefix) {
+				jobs = append(jobs, job)
+			}
+		}
+		return nil
+	}
+	if err := c.Client.Jobs(ctx, visit); err != nil {
+		return nil, err
+	}
+	results := []*JobHealth{}
+	unhealthy := 0
+	for _, job := range jobs {
+		h, err := c.checkJob(ctx, job)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check %q: %w", job.Name, err)
+		}
+		results = append(results, h)
+		if !h.Healthy() {
+			unhealthy++
+		}
+		success := "never"
+		if !h.LastSuccess.IsZero() {
+			success = h.LastSuccess.Format(time.RFC3339)
+		}
+		fmt.Fprintf(c.Output, "%-7s %-25s last:%-11s success:%-20s window:%-8s desc:%q\n",
+			h.Health, h.Name, h.LastStatus, success, h.Window, h.Description)
+	}
+	fmt.Fprintf(c.Output, "healthy:%d unhealthy:%d\n", len(results)-unhealthy, unhealthy)
+	if unhealthy > 0 {
+		return results, fmt.Errorf("%d of %d jobs are unhealthy", unhealthy, len(results))
+	}
+	return results, nil
+}
+
+// checkJob returns the health of job based on its operations.
+func (c *Command) checkJob(ctx context.Context, job *storagetransfer.TransferJob) (*JobHealth, error) {
+	h := &JobHealth{
+		Name:        job.Name,
+		Description: job.Description,
+		Window:      window(job),
+	}
+	last, lastSuccess, err := c.lastFinished(ctx, job.Name)
+	if err != nil {
+		return nil, err
+	}
+	h.LastSuccess = lastSuccess
+	cutoff := time.Now().Add(-h.Window)
+	if last != nil {
+		h.LastStatus = last.Status
+	}
+	switch {
+	case last != nil && last.Status != "SUCCESS":
+		h.Health = HealthFailed
+	case last != nil && parseCount(last.Counters.ObjectsFromSourceFailed) > 0:
+		h.Health = HealthErrors
+	case h.LastSuccess.After(cutoff):
+		h.Health = HealthOK
+	case last == nil && created(job).After(cutoff):
+		// Recently created jobs may not have run yet.
+		h.Health = HealthNew
+	default:
+		h.Health = HealthStale
+	}
+	return h, nil
+}
+
+// lastFinished returns the metadata of the most recently started finished
+// operation of the named job, or nil if none has finished, and the start time
+// of the most recent successful operation, or the zero time if none succeeded.
+func (c *Command) lastFinished(ctx context.Context, name string) (*jobMetadata, time.Time, error) {
+	var last *jobMetadata
+	var lastSuccess time.Time
+	visit := func(r *storagetransfer.ListOperationsResponse) error {
+		for _, op := range r.Operations {
+			if !op.Done {
+				continue
+			}
+			m := parseJobMetadata(op.Metadata)
+			if last == nil || m.Start.After(last.Start) {
+				last = m
+			}
+			if m.Status == "SUCCESS" && m.Start.After(lastSuccess) {
+				lastSuccess = m.Start
+			}
+		}
+		return nil
+	}
+	err := c.Client.Operations(ctx, name, visit)
+	return last, lastSuccess, err
+}
+
+// created returns the creation time of job, or the zero time if unknown.
+func created(job *storagetransfer.TransferJob) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, job.CreationTime)
+	return t
+}
@@ -0,0 +1,127 @@
+package stctl_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/m-lab/gcp-config/internal/stctl"
+	"google.golang.org/api/storagetransfer/v1"
+)
+
+func TestCommand_Check(t *testing.T) {
+	now := time.Now()
+	op := func(start time.Time, status, failed string) *storagetransfer.Operation {
+		m := jobMetadata{Start: start, Status: status}
+		m.Counters.ObjectsFromSourceFailed = failed
+		return &storagetransfer.Operation{Done: true, Metadata: md2JSON(m)}
+	}
+	ops := func(ops ...*storagetransfer.Operation) *storagetransfer.ListOperationsResponse {
+		return &storagetransfer.ListOperationsResponse{Operations: ops}
+	}
+	job := func(name, interval string, created time.Time) *storagetransfer.TransferJob {
+		return &storagetransfer.TransferJob{
+			Name:         name,
+			Description:  "STCTL: " + name,
+			CreationTime: created.Format(time.RFC3339),
+			Schedule:     &storagetransfer.Schedule{RepeatInterval: interval},
+		}
+	}
+	old := now.Add(-30 * 24 * time.Hour)
+	tests := []struct {
+		name string
+		job  *storagetransfer.TransferJob
+		ops  *storagetransfer.ListOperationsResponse
+		want stctl.Health
+	}{
+		{
+			name: "ok",
+			job:  job("transferJobs/ok", "", old),
+			ops:  ops(op(now.Add(-48*time.Hour), "FAILED", ""), op(now.Add(-24*time.Hour), "SUCCESS", "")),
+			want: stctl.HealthOK,
+		},
+		{
+			name: "ok-ignores-running",
+			job:  job("transferJobs/running", "", old),
+			ops: ops(op(now.Add(-24*time.Hour), "SUCCESS", ""),
+				&storagetransfer.Operation{Metadata: md2JSON(jobMetadata{Start: now, Status: "IN_PROGRESS"})}),
+			want: stctl.HealthOK,
+		},
+		{
+			name: "new",
+			job:  job("transferJobs/new", "", now.Add(-time.Hour)),
+			ops:  ops(),
+			want: stctl.HealthNew,
+		},
+		{
+			name: "failed",
+			job:  job("transferJobs/failed", "", old),
+			ops:  ops(op(now.Add(-48*time.Hour), "SUCCESS", ""), op(now.Add(-24*time.Hour), "ABORTED", "")),
+			want: stctl.HealthFailed,
+		},
+		{
+			name: "errors",
+			job:  job("transferJobs/errors", "", old),
+			ops:  ops(op(now.Add(-24*time.Hour), "SUCCESS", "3")),
+			want: stctl.HealthErrors,
+		},
+		{
+			name: "stale-daily",
+			job:  job("transferJobs/stale-daily", "", old),
+			ops:  ops(op(now.Add(-72*time.Hour), "SUCCESS", "")),
+			want: stctl.HealthStale,
+		},
+		{
+			name: "stale-interval",
+			job:  job("transferJobs/stale-interval", "3600s", old),
+			ops:  ops(op(now.Add(-3*time.Hour), "SUCCESS", "")),
+			want: stctl.HealthStale,
+		},
+		{
+			name: "stale-never-ran",
+			job:  job("transferJobs/never-ran", "", old),
+			ops:  ops(),
+			want: stctl.HealthStale,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := &bytes.Buffer{}
+			c := &Command{
+				Output: output,
+				Client: &fakeTJ{
+					listJobResp: &storagetransfer.ListTransferJobsResponse{
+						TransferJobs: []*storagetransfer.TransferJob{
+							tt.job,
+							{Name: "transferJobs/unmanaged", Description: "manual"},
+						},
+					},
+					jobOpsResp: map[string]*storagetransfer.ListOperationsResponse{tt.job.Name: tt.ops},
+				},
+			}
+			got, err := c.Check(context.Background())
+			if len(got) != 1 {
+				t.Fatalf("Command.Check() wrong number of results; got %d, want 1", len(got))
+			}
+			if got[0].Health != tt.want {
+				t.Errorf("Command.Check() = %q, want %q", got[0].Health, tt.want)
+			}
+			if (err != nil) == got[0].Healthy() {
+				t.Errorf("Command.Check() error = %v, healthy %t", err, got[0].Healthy())
+			}
+			if !strings.HasPrefix(output.String(), string(tt.want)) {
+				t.Errorf("Command.Check() wrong status line: %q", output.String())
+			}
+		})
+	}
+}
+
+func TestCommand_Check_listError(t *testing.T) {
+	c := &Command{Client: &fakeTJ{listErr: errors.New("fake list error")}, Output: &bytes.Buffer{}}
+	if _, err := c.Check(context.Background()); err == nil {
+		t.Errorf("Command.Check() expected error")
+	}
+}
@@ -20,6 +20,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"sort"
+	"strconv"
 	"time"
 
 	"github.com/m-lab/go/flagx"
@@ -34,16 +36,29 @@ import (
 // TransferJob captures the interface required by Command implementations.
 type TransferJob interface {
 	Jobs(ctx context.Context, visit func(resp *storagetransfer.ListTransferJobsResponse) error) error
+	DisabledJobs(ctx context.Context, visit func(resp *storagetransfer.ListTransferJobsResponse) error) error
 	Create(ctx context.Context, create *storagetransfer.TransferJob) (*storagetransfer.TransferJob, error)
 	Get(ctx context.Context, name string) (*storagetransfer.TransferJob, error)
 	Update(ctx context.Context, name string, update *storagetransfer.UpdateTransferJobRequest) (*storagetransfer.TransferJob, error)
 	Operations(ctx context.Context, name string, visit func(r *storagetransfer.ListOperationsResponse) error) error
+	Run(ctx context.Context, name string) (*storagetransfer.Operation, error)
+	Operation(ctx context.Context, name string) (*storagetransfer.Operation, error)
+	Pause(ctx context.Context, name string) error
+	Resume(ctx context.Context, name string) error
+	Cancel(ctx context.Context, name string) error
+}
+
+// ObjectWriter captures the interface required to write transfer manifests.
+type ObjectWriter interface {
+	Write(ctx context.Context, bucket, name string, content []byte) error
 }
 
 // Command executes stctl actions.
 type Command struct {
 	Client              TransferJob
+	Objects             ObjectWriter
 	Project             string
+	Key                 string // Optional job identity, independent of buckets and start time.
 	SourceBucket        string
 	TargetBucket        string
 	Prefixes            []string
@@ -54,31 +69,53 @@ type Command struct {
 	MaxFileAge          time.Duration
 	DeleteAfterTransfer bool
 	Output              io.Writer
+	Format              string // Output format of list commands, e.g. FormatTable.
+	SortBy              string // Sort order of list commands, e.g. SortStart.
 }
 
-// ListJobs lists enabled transfer jobs.
+// ListJobs lists enabled transfer jobs in c.Format, sorted by c.SortBy.
 func (c *Command) ListJobs(ctx context.Context) error {
+	if err := c.checkFormat(); err != nil {
+		return err
+	}
+	records := []*jobRecord{}
 	visit := func(resp *storagetransfer.ListTransferJobsResponse) error {
 		for _, job := range resp.TransferJobs {
 			// NB: One-time jobs have equal ScheduleStartDate and ScheduleEndDate.
 			// We only manage daily jobs that never terminate, which have no ScheduleEndDate.
-			if job.Schedule.ScheduleEndDate == nil {
+			if job.Schedule != nil && job.Schedule.ScheduleEndDate == nil {
 				logx.Debug.Print(pretty.Sprint(job))
-				including := "AllPrefixes"
-				if job.TransferSpec.ObjectConditions != nil {
-					including = fmt.Sprintf("%v", job.TransferSpec.ObjectConditions.IncludePrefixes)
-				}
-				fmt.Fprintf(c.Output, "%-25s starting:%s desc:%q including:%v\n",
-					job.Name, fmtTime(job.Schedule.StartTimeOfDay), job.Description, including)
+				records = append(records, newJobRecord(job))
 			}
 		}
 		return nil
 	}
-	return c.Client.Jobs(ctx, visit)
+	if err := c.Client.Jobs(ctx, visit); err != nil {
+		return err
+	}
+	if c.SortBy != SortNone {
+		sort.SliceStable(records, func(i, k int) bool {
+			a, b := records[i], records[k]
+			if c.SortBy == SortStart && a.Start != b.Start {
+				return a.Start < b.Start
+			}
+			return a.Name < b.Name
+		})
+	}
+	rows := [][]string{}
+	for _, r := range records {
+		rows = append(rows, r.row())
+	}
+	return writeRecords(c.Output, c.Format, jobHeader, rows, records)
 }
 
-// ListOperations lists past operations for the named job that started after c.AfterDate.
+// ListOperations lists past operations for the named job that started after
+// c.AfterDate in c.Format, sorted by c.SortBy.
 func (c *Command) ListOperations(ctx context.Context, name string) error {
+	if err := c.checkFormat(); err != nil {
+		return err
+	}
+	records := []*operationRecord{}
 	visit := func(r *storagetransfer.ListOperationsResponse) error {
 		for _, op := range r.Operations {
 			m := parseJobMetadata(op.Metadata)
@@ -87,27 +124,30 @@ func (c *Command) ListOperations(ctx context.Context, name string) error {
 				continue
 			}
 			logx.Debug.Print(pretty.Sprint(op))
-			if m.TransferSpec == nil || m.TransferSpec.ObjectConditions == nil {
+			if m.TransferSpec == nil {
 				continue
 			}
-			fmt.Fprintf(c.Output,
-				("Copy %s to %s including:%v :: " +
-					"Found %-7s Copied %-7s Skipped %-8s Failed %2q :: " +
-					"Lasted %f minutes with Status %s Started %s\n"),
-				m.TransferSpec.GcsDataSource.BucketName, m.TransferSpec.GcsDataSink.BucketName,
-				m.TransferSpec.ObjectConditions.IncludePrefixes,
-				m.Counters.ObjectsFound,
-				m.Counters.ObjectsCopied,
-				m.Counters.ObjectsFromSourceSkippedBySync,
-				m.Counters.ObjectsFromSourceFailed,
-				m.End.Sub(m.Start).Minutes(),
-				m.Status,
-				m.Start,
-			)
+			records = append(records, newOperationRecord(op.Name, m))
 		}
 		return nil
 	}
-	return c.Client.Operations(ctx, name, visit)
+	if err := c.Client.Operations(ctx, name, visit); err != nil {
+		return err
+	}
+	if c.SortBy != SortNone {
+		sort.SliceStable(records, func(i, k int) bool {
+			a, b := records[i], records[k]
+			if c.SortBy == SortStart && !a.Start.Equal(b.Start) {
+				return a.Start.Before(b.Start)
+			}
+			return a.Name < b.Name
+		})
+	}
+	rows := [][]string{}
+	for _, r := range records {
+		rows = append(rows, r.row())
+	}
+	return writeRecords(c.Output, c.Format, operationHeader, rows, records)
 }
 
 func (c *Command) getSpec() storagetransfer.TransferSpec {
@@ -148,6 +188,10 @@ func fmtTime(t *storagetransfer.TimeOfDay) string {
 // The Metadata field of storagetransfer.TransferOperation must be parsed from a
 // JSON blob. The structs below are a subset of fields available.
 func parseJobMetadata(m googleapi.RawMessage) *jobMetadata {
+	if len(m) == 0 {
+		// Operations may not report metadata, e.g. before they start.
+		return &jobMetadata{}
+	}
 	b, err := m.MarshalJSON()
 	rtx.Must(err, "failed to marshal json of raw message")
 	j := &jobMetadata{}
@@ -155,11 +199,41 @@ func parseJobMetadata(m googleapi.RawMessage) *jobMetadata {
 	return j
 }
 
+// parseCount parses a counter from the ST API, which omits zero values.
+func parseCount(s string) int64 {
+	// Accept that an error parsing correctly means zero.
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
+
 type counters struct {
 	ObjectsFound                   string `json:"objectsFoundFromSource"`
 	ObjectsCopied                  string `json:"objectsCopiedToSink"`
 	ObjectsFromSourceSkippedBySync string `json:"objectsFromSourceSkippedBySync"`
 	ObjectsFromSourceFailed        string `json:"objectsFromSourceFailed"`
+	BytesFound                     string `json:"bytesFoundFromSource"`
+	BytesCopied                    string `json:"bytesCopiedToSink"`
+	BytesFromSourceSkippedBySync   string `json:"bytesFromSourceSkippedBySync"`
+	BytesFromSourceFailed          string `json:"bytesFromSourceFailed"`
+	ObjectsDeletedFromSource       string `json:"objectsDeletedFromSource"`
+	BytesDeletedFromSource         string `json:"bytesDeletedFromSource"`
+	ObjectsDeletedFromSink         string `json:"objectsDeletedFromSink"`
+	BytesDeletedFromSink           string `json:"bytesDeletedFromSink"`
+	ObjectsFailedToDeleteFromSink  string `json:"objectsFailedToDeleteFromSink"`
+	BytesFailedToDeleteFromSink    string `json:"bytesFailedToDeleteFromSink"`
+}
+
+// errorSummary counts the errors of one error code, with a sample of the
+// failed objects.
+type errorSummary struct {
+	ErrorCode       string          `json:"errorCode"`
+	ErrorCount      string          `json:"errorCount"`
+	ErrorLogEntries []errorLogEntry `json:"errorLogEntries"`
+}
+
+type errorLogEntry struct {
+	URL          string   `json:"url"`
+	ErrorDetails []string `json:"errorDetails"`
 }
 
 type jobMetadata struct {
@@ -168,4 +242,7 @@ type jobMetadata struct {
 	End          time.Time                     `json:"endTime"`
 	Counters     counters                      `json:"counters"`
 	Status       string                        `json:"status"`
+
+	TransferJobName string         `json:"transferJobName"`
+	ErrorBreakdowns []errorSummary `json:"errorBreakdowns"`
 }
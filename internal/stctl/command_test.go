@@ -39,6 +39,18 @@ type fakeTJ struct {
 	getErr      error
 	updateErr   error
 	createErr   error
+	runErr      error
+	opErr       error
+	controlErr  error
+	// controlled records the names passed to Pause, Resume and Cancel.
+	controlled []string
+	// ops are returned by successive calls to Operation. The last is repeated.
+	ops []*storagetransfer.Operation
+
+	// disabledJobResp is visited by DisabledJobs.
+	disabledJobResp *storagetransfer.ListTransferJobsResponse
+	// jobOpsResp, if set, is visited by Operations instead of listOpsResp.
+	jobOpsResp map[string]*storagetransfer.ListOperationsResponse
 }
 
 func (f *fakeTJ) Jobs(ctx context.Context, visit func(resp *storagetransfer.ListTransferJobsResponse) error) error {
@@ -48,6 +60,13 @@ func (f *fakeTJ) Jobs(ctx context.Context, visit func(resp *storagetransfer.List
 	return visit(f.listJobResp)
 }
 
+func (f *fakeTJ) DisabledJobs(ctx context.Context, visit func(resp *storagetransfer.ListTransferJobsResponse) error) error {
+	if f.listErr != nil {
+		return f.listErr
+	}
+	return visit(f.disabledJobResp)
+}
+
 func (f *fakeTJ) Create(ctx context.Context, create *storagetransfer.TransferJob) (*storagetransfer.TransferJob, error) {
 	if f.createErr != nil {
 		return nil, f.createErr
@@ -68,15 +87,65 @@ func (f *fakeTJ) Update(ctx context.Context, name string, update *storagetransfe
 		return nil, f.updateErr
 	}
 	if f.job != nil {
+		f.job.Description = update.TransferJob.Description
 		f.job.Status = update.TransferJob.Status
+		f.job.TransferSpec = update.TransferJob.TransferSpec
 	}
 	return f.job, nil
 }
 
 func (f *fakeTJ) Operations(ctx context.Context, name string, visit func(r *storagetransfer.ListOperationsResponse) error) error {
+	if f.listErr != nil {
+		return f.listErr
+	}
+	if f.jobOpsResp != nil {
+		resp, ok := f.jobOpsResp[name]
+		if !ok {
+			resp = &storagetransfer.ListOperationsResponse{}
+		}
+		return visit(resp)
+	}
 	return visit(f.listOpsResp)
 }
 
+func (f *fakeTJ) Run(ctx context.Context, name string) (*storagetransfer.Operation, error) {
+	if f.runErr != nil {
+		return nil, f.runErr
+	}
+	return &storagetransfer.Operation{Name: "transferOperations/" + name + "-fake-run"}, nil
+}
+
+func (f *fakeTJ) Operation(ctx context.Context, name string) (*storagetransfer.Operation, error) {
+	if f.opErr != nil {
+		return nil, f.opErr
+	}
+	op := f.ops[0]
+	if len(f.ops) > 1 {
+		f.ops = f.ops[1:]
+	}
+	return op, nil
+}
+
+func (f *fakeTJ) control(name string) error {
+	if f.controlErr != nil {
+		return f.controlErr
+	}
+	f.controlled = append(f.controlled, name)
+	return nil
+}
+
+func (f *fakeTJ) Pause(ctx context.Context, name string) error {
+	return f.control(name)
+}
+
+func (f *fakeTJ) Resume(ctx context.Context, name string) error {
+	return f.control(name)
+}
+
+func (f *fakeTJ) Cancel(ctx context.Context, name string) error {
+	return f.control(name)
+}
+
 func TestCommand_ListJobs(t *testing.T) {
 	output := &bytes.Buffer{}
 	tests := []struct {
@@ -125,9 +194,10 @@ func TestCommand_ListJobs(t *testing.T) {
 			}
 		})
 	}
+	// A header and one job.
 	c := strings.Count(output.String(), "\n")
-	if c != 1 {
-		t.Errorf("Command.ListJobs() wrote wrong number of lines; got %d, want 1", c)
+	if c != 2 {
+		t.Errorf("Command.ListJobs() wrote wrong number of lines; got %d, want 2", c)
 	}
 }
 
@@ -225,8 +295,9 @@ func TestCommand_ListOperations(t *testing.T) {
 			}
 		})
 	}
-	c := strings.Count(output.String(), "\n")
+	// Every case writes a header, but only one operation.
+	c := strings.Count(output.String(), "transferOperations/")
 	if c != 1 {
-		t.Errorf("Command.ListOperations() wrote wrong number of lines; got %d, want 1", c)
+		t.Errorf("Command.ListOperations() wrote wrong number of operations; got %d, want 1", c)
 	}
 }
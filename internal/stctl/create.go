@@ -3,6 +3,7 @@ package stctl
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/m-lab/go/flagx"
@@ -15,7 +16,7 @@ import (
 // Create creates a new storage transfer job.
 func (c *Command) Create(ctx context.Context) (*storagetransfer.TransferJob, error) {
 	spec := c.getSpec()
-	desc := getDesc(c.SourceBucket, c.TargetBucket, c.StartTime)
+	desc := c.description()
 	ts := time.Now().UTC()
 	create := &storagetransfer.TransferJob{
 		Description: desc,
@@ -45,8 +46,54 @@ func (c *Command) Create(ctx context.Context) (*storagetransfer.TransferJob, err
 	return c.Client.Create(ctx, create)
 }
 
+// managedPrefix is the description prefix of all jobs managed by stctl.
+const managedPrefix = "STCTL:"
+
 // getDesc returns the canonical description used to identify previously created
 // jobs. WARNING: Do not modify this format without adjusting existing configs to match.
 func getDesc(src, dest string, start flagx.Time) string {
-	return fmt.Sprintf("STCTL: transfer %s -> %s at %s", src, dest, start)
+	return fmt.Sprintf(managedPrefix+" transfer %s -> %s at %s", src, dest, start)
+}
+
+// keyPrefix precedes the user-chosen key in the description of keyed jobs.
+const keyPrefix = managedPrefix + " key="
+
+// getKeyDesc returns the description of a job identified by key. Unlike
+// getDesc, only the key identifies the job, so the source, target, and start
+// time may change without creating a new logical job.
+func getKeyDesc(key, src, dest string, start flagx.Time) string {
+	return fmt.Sprintf(keyPrefix+"%s transfer %s -> %s at %s", key, src, dest, start)
+}
+
+// description returns the description for jobs created by c.
+func (c *Command) description() string {
+	if c.Key != "" {
+		return getKeyDesc(c.Key, c.SourceBucket, c.TargetBucket, c.StartTime)
+	}
+	return getDesc(c.SourceBucket, c.TargetBucket, c.StartTime)
+}
+
+// identity returns the identity of the logical job described by c.
+func (c *Command) identity() string {
+	if c.Key != "" {
+		return "key=" + c.Key
+	}
+	return getDesc(c.SourceBucket, c.TargetBucket, c.StartTime)
+}
+
+// jobIdentity returns the identity of an existing job from its description.
+// Keyed jobs are identified by key. All other jobs are identified by the full
+// description.
+func jobIdentity(desc string) string {
+	if rest, ok := strings.CutPrefix(desc, keyPrefix); ok {
+		if key, _, _ := strings.Cut(rest, " "); key != "" {
+			return "key=" + key
+		}
+	}
+	return desc
+}
+
+// validKey reports whether key may be used as a job identity.
+func validKey(key string) bool {
+	return key != "" && !strings.ContainsAny(key, " \t\n")
 }
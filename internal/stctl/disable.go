@@ -11,6 +11,24 @@ import (
 
 // Disable marks the job status as 'DISABLED'.
 func (c *Command) Disable(ctx context.Context, name string) (*storagetransfer.TransferJob, error) {
+	// NOTE: we prefer disabled status to preserve transfer history in the web UI.
+	return c.setStatus(ctx, name, "DISABLED") // No longer scheduled. Still visible in web UI.
+}
+
+// Enable marks the job status as 'ENABLED', e.g. to restore a job disabled by mistake.
+func (c *Command) Enable(ctx context.Context, name string) (*storagetransfer.TransferJob, error) {
+	return c.setStatus(ctx, name, "ENABLED")
+}
+
+// Delete marks the job status as 'DELETED'. Deleted jobs and their transfer
+// history are no longer visible in the web UI and cannot be restored.
+func (c *Command) Delete(ctx context.Context, name string) (*storagetransfer.TransferJob, error) {
+	return c.setStatus(ctx, name, "DELETED")
+}
+
+// setStatus updates the status of the named job, preserving its description
+// and transfer spec.
+func (c *Command) setStatus(ctx context.Context, name, status string) (*storagetransfer.TransferJob, error) {
 	current, err := c.Client.Get(ctx, name)
 	if err != nil {
 		return nil, err
@@ -24,8 +42,7 @@ func (c *Command) Disable(ctx context.Context, name string) (*storagetransfer.Tr
 			Description:  current.Description,
 			TransferSpec: current.TransferSpec,
 			// "ENABLED", "DISABLED", "DELETED"
-			// NOTE: we prefer disabled status to preserve transfer history in the web UI.
-			Status: "DISABLED", // No longer scheduled. Still visible in web UI.
+			Status: status,
 		},
 	}
 	logx.Debug.Print(pretty.Sprint(update))
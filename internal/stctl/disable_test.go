@@ -86,3 +86,52 @@ func TestCommand_Disable(t *testing.T) {
 		})
 	}
 }
+
+func TestCommand_EnableDelete(t *testing.T) {
+	tests := []struct {
+		name   string
+		action func(c *Command, ctx context.Context, name string) (*storagetransfer.TransferJob, error)
+		want   string
+	}{
+		{
+			name:   "enable",
+			action: (*Command).Enable,
+			want:   "ENABLED",
+		},
+		{
+			name:   "delete",
+			action: (*Command).Delete,
+			want:   "DELETED",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := &storagetransfer.TransferSpec{
+				GcsDataSource: &storagetransfer.GcsData{BucketName: "source"},
+			}
+			c := &Command{
+				Client: &fakeTJ{
+					job: &storagetransfer.TransferJob{
+						Name:         "job-name",
+						Description:  "This is the job description",
+						Status:       "DISABLED",
+						TransferSpec: spec,
+					},
+				},
+			}
+			job, err := tt.action(c, context.Background(), "job-name")
+			if err != nil {
+				t.Fatalf("Command.%s() error = %v", tt.name, err)
+			}
+			expected := &storagetransfer.TransferJob{
+				Name:         "job-name",
+				Description:  "This is the job description",
+				Status:       tt.want,
+				TransferSpec: spec,
+			}
+			if diff := deep.Equal(job, expected); diff != nil {
+				t.Errorf("Command.%s() job did not match expected; %v", tt.name, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package stctl
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/api/storagetransfer/v1"
+)
+
+// Resolutions for duplicate jobs found by Doctor.
+const (
+	// ResolveNone only reports duplicates.
+	ResolveNone = ""
+	// ResolveNewest keeps the most recently created job.
+	ResolveNewest = "newest"
+	// ResolveSpec keeps the job matching the desired spec in the config.
+	ResolveSpec = "spec"
+)
+
+// Duplicate describes managed jobs that share the same identity.
+type Duplicate struct {
+	Identity string
+	// Jobs are ordered from newest to oldest.
+	Jobs []*storagetransfer.TransferJob
+	// Conflict is true when the jobs have different specs.
+	Conflict bool
+	// Keep is the job kept by the resolution, if any.
+	Keep *storagetransfer.TransferJob
+}
+
+// jobSummary summarizes the spec and schedule of a job for comparison.
+func jobSummary(job *storagetransfer.TransferJob) string {
+	c := &Command{}
+	if job.TransferSpec != nil {
+		c.SourceBucket = bucketName(job.TransferSpec.GcsDataSource)
+		c.TargetBucket = bucketName(job.TransferSpec.GcsDataSink)
+	}
+	// Compare to an empty command so that every set field is reported.
+	diffs := c.specDiff(job)
+	fields := []string{"source:" + c.SourceBucket, "target:" + c.TargetBucket}
+	if job.Schedule != nil && job.Schedule.RepeatInterval != "" {
+		fields = append(fields, "interval:"+job.Schedule.RepeatInterval)
+	}
+	for _, d := range diffs {
+		fields = append(fields, d.Field+":"+d.Current)
+	}
+	return strings.Join(fields, " ")
+}
+
+// Doctor scans all managed jobs, groups them by identity and reports every
+// identity with more than one job. With ResolveNewest or ResolveSpec, all
+// jobs but the one kept are disabled. Unless confirm is true, Doctor only
+// reports the jobs it would disable. The cfg is only needed for ResolveSpec.
+func (c *Command) Doctor(ctx context.Context, cfg *Config, resolve string, confirm bool) ([]*Duplicate, error) {
+	if resolve != ResolveNone && resolve != ResolveNewest && resolve != ResolveSpec {
+		return nil, fmt.Errorf("unknown resolution %q", resolve)
+	}
+	desired := map[string]*Command{}
+	if resolve == ResolveSpec {
+		if cfg == nil {
+			return nil, fmt.Errorf("resolving by spec requires a config")
+		}
+		for i := range cfg.Jobs {
+			j := &cfg.Jobs[i]
+			if !j.selected(c.Project) {
+				continue
+			}
+			jc, err := c.forJob(j)
+			if err != nil {
+				return nil, err
+			}
+			desired[jc.identity()] = jc
+		}
+	}
+
+	groups := map[string][]*storagetransfer.TransferJob{}
+	visit := func(resp *storagetransfer.ListTransferJobsResponse) error {
+		for _, job := range resp.TransferJobs {
+			if strings.HasPrefix(job.Description, managedPrefix) {
+				id := jobIdentity(job.Description)
+				groups[id] = append(groups[id], job)
+			}
+		}
+		return nil
+	}
+	if err := c.Client.Jobs(ctx, visit); err != nil {
+		return nil, err
+	}
+
+	dups := []*Duplicate{}
+	for id, jobs := range groups {
+		if len(jobs) < 2 {
+			continue
+		}
+		// Sort newest first. Creation times may differ in precision, so
+		// compare them as times rather than strings.
+		sort.Slice(jobs, func(i, k int) bool { return created(jobs[i]).After(created(jobs[k])) })
+		d := &Duplicate{Identity: id, Jobs: jobs}
+		for _, job := range jobs[1:] {
+			if jobSummary(job) != jobSummary(jobs[0]) {
+				d.Conflict = true
+			}
+		}
+		switch resolve {
+		case ResolveNewest:
+			d.Keep = jobs[0]
+		case ResolveSpec:
+			if jc, ok := desired[id]; ok {
+				for _, job := range jobs {
+					if len(jc.specDiff(job)) == 0 {
+						d.Keep = job
+						break
+					}
+				}
+			}
+		}
+		dups = append(dups, d)
+	}
+	sort.Slice(dups, func(i, k int) bool { return dups[i].Identity < dups[k].Identity })
+
+	for _, d := range dups {
+		fmt.Fprintf(c.Output, "duplicate %q jobs:%d conflict:%t\n", d.Identity, len(d.Jobs), d.Conflict)
+		for _, job := range d.Jobs {
+			action := ""
+			switch {
+			case d.Keep == nil:
+			case job == d.Keep:
+				action = "keep"
+			case !confirm:
+				action = "would disable"
+			default:
+				if _, err := c.Disable(ctx, job.Name); err != nil {
+					return nil, fmt.Errorf("failed to disable %q: %w", job.Name, err)
+				}
+				action = "disabled"
+			}
+			fmt.Fprintf(c.Output, "  %-13s %-25s created:%s %s\n", action, job.Name, job.CreationTime, jobSummary(job))
+		}
+		if resolve != ResolveNone && d.Keep == nil {
+			fmt.Fprintln(c.Output, "  no job matches the desired spec; not resolved")
+		}
+	}
+	if resolve != ResolveNone && !confirm && len(dups) > 0 {
+		fmt.Fprintln(c.Output, "dry run: use -confirm to disable jobs")
+	}
+	fmt.Fprintf(c.Output, "duplicates:%d\n", len(dups))
+	return dups, nil
+}
@@ -0,0 +1,140 @@
+package stctl_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/m-lab/gcp-config/internal/stctl"
+	"github.com/m-lab/go/flagx"
+	storagetransfer "google.golang.org/api/storagetransfer/v1"
+)
+
+func TestCommand_Doctor(t *testing.T) {
+	start := flagx.Time{Hour: 1, Minute: 2, Second: 3}
+	job := func(name, created string, prefixes ...string) *storagetransfer.TransferJob {
+		return &storagetransfer.TransferJob{
+			Name:         "transferJobs/" + name,
+			Description:  getDesc("fake-source", "fake-target", start),
+			CreationTime: created,
+			Schedule: &storagetransfer.Schedule{
+				StartTimeOfDay: &storagetransfer.TimeOfDay{Hours: 1, Minutes: 2, Seconds: 3},
+			},
+			TransferSpec: &storagetransfer.TransferSpec{
+				GcsDataSource:    &storagetransfer.GcsData{BucketName: "fake-source"},
+				GcsDataSink:      &storagetransfer.GcsData{BucketName: "fake-target"},
+				ObjectConditions: &storagetransfer.ObjectConditions{IncludePrefixes: prefixes},
+			},
+		}
+	}
+	jobs := func(newest, middle string) *storagetransfer.ListTransferJobsResponse {
+		keyed := job("keyed", "2021-01-01T00:00:00Z")
+		keyed.Description = getKeyDesc("archive", "other-source", "fake-target", start)
+		return &storagetransfer.ListTransferJobsResponse{
+			TransferJobs: []*storagetransfer.TransferJob{
+				job("oldest", "2019-01-01T00:00:00Z", "a"),
+				job("newest", newest, "b"),
+				job("middle", middle, "a"),
+				keyed,
+				{Name: "transferJobs/unmanaged", Description: "manual"},
+				{Name: "transferJobs/unmanaged-duplicate", Description: "manual"},
+			},
+		}
+	}
+	cfg := &stctl.Config{
+		Jobs: []stctl.JobConfig{
+			{Source: "fake-source", Target: "fake-target", Time: "01:02:03", Include: []string{"a"}},
+		},
+	}
+	tests := []struct {
+		name     string
+		newest   string
+		middle   string
+		cfg      *stctl.Config
+		resolve  string
+		confirm  bool
+		wantKeep string
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name: "report",
+			want: []string{"conflict:true", "duplicates:1"},
+		},
+		{
+			name:     "newest-dry-run",
+			resolve:  stctl.ResolveNewest,
+			wantKeep: "transferJobs/newest",
+			want:     []string{"keep          transferJobs/newest", "would disable transferJobs/middle", "would disable transferJobs/oldest"},
+		},
+		{
+			name:     "newest-mixed-precision",
+			newest:   "2021-01-01T00:00:00.5Z",
+			middle:   "2021-01-01T00:00:00Z",
+			resolve:  stctl.ResolveNewest,
+			wantKeep: "transferJobs/newest",
+			want:     []string{"keep          transferJobs/newest", "would disable transferJobs/middle"},
+		},
+		{
+			name:     "spec-confirm",
+			cfg:      cfg,
+			resolve:  stctl.ResolveSpec,
+			confirm:  true,
+			wantKeep: "transferJobs/middle",
+			want:     []string{"disabled      transferJobs/newest", "keep          transferJobs/middle", "disabled      transferJobs/oldest"},
+		},
+		{
+			name:    "error-spec-without-config",
+			resolve: stctl.ResolveSpec,
+			wantErr: true,
+		},
+		{
+			name:    "error-unknown-resolution",
+			resolve: "oldest",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.newest == "" {
+				tt.newest, tt.middle = "2021-01-01T00:00:00Z", "2020-01-01T00:00:00Z"
+			}
+			output := &bytes.Buffer{}
+			c := &Command{
+				Client: &fakeTJ{listJobResp: jobs(tt.newest, tt.middle), job: &storagetransfer.TransferJob{}},
+				Output: output,
+			}
+			got, err := c.Doctor(context.Background(), tt.cfg, tt.resolve, tt.confirm)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Command.Doctor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != 1 || len(got[0].Jobs) != 3 {
+				t.Fatalf("Command.Doctor() wrong duplicates; got %v", got)
+			}
+			keep := ""
+			if got[0].Keep != nil {
+				keep = got[0].Keep.Name
+			}
+			if keep != tt.wantKeep {
+				t.Errorf("Command.Doctor() keep = %q, want %q", keep, tt.wantKeep)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(output.String(), want) {
+					t.Errorf("Command.Doctor() missing %q in output:\n%s", want, output.String())
+				}
+			}
+		})
+	}
+}
+
+func TestCommand_Doctor_listError(t *testing.T) {
+	c := &Command{Client: &fakeTJ{listErr: errors.New("fake list error")}, Output: &bytes.Buffer{}}
+	if _, err := c.Doctor(context.Background(), nil, stctl.ResolveNone, false); err == nil {
+		t.Errorf("Command.Doctor() expected error")
+	}
+}
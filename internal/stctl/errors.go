@@ -0,0 +1,27 @@
+package stctl
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Errors prints the errors of the named operation by error code, with the
+// URLs and details of the sample failed objects reported by the API.
+func (c *Command) Errors(ctx context.Context, name string) error {
+	op, err := c.Client.Operation(ctx, name)
+	if err != nil {
+		return err
+	}
+	m := parseJobMetadata(op.Metadata)
+	r := newOperationRecord(op.Name, m)
+	fmt.Fprintf(c.Output, "%s status:%s failed:%d bytesFailed:%d errors:%d\n",
+		r.Name, r.Status, r.ObjectsFailed, r.BytesFailed, r.Errors)
+	for _, e := range m.ErrorBreakdowns {
+		fmt.Fprintf(c.Output, "  %-25s count:%d\n", e.ErrorCode, parseCount(e.ErrorCount))
+		for _, entry := range e.ErrorLogEntries {
+			fmt.Fprintf(c.Output, "    %s %s\n", entry.URL, strings.Join(entry.ErrorDetails, "; "))
+		}
+	}
+	return nil
+}
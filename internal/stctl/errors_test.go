@@ -0,0 +1,119 @@
+package stctl_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/m-lab/gcp-config/internal/stctl"
+	"google.golang.org/api/storagetransfer/v1"
+)
+
+// failedMetadata returns operation metadata with two error codes.
+func failedMetadata() []byte {
+	return []byte(`{
+		"transferJobName": "transferJobs/fake",
+		"transferSpec": {"gcsDataSource": {"bucketName": "source"}},
+		"status": "FAILED",
+		"counters": {
+			"objectsFromSourceFailed": "3",
+			"bytesFromSourceFailed": "300",
+			"objectsDeletedFromSource": "5",
+			"objectsDeletedFromSink": "2"
+		},
+		"errorBreakdowns": [
+			{
+				"errorCode": "NOT_FOUND",
+				"errorCount": "2",
+				"errorLogEntries": [
+					{"url": "gs://source/ndt/a.tgz", "errorDetails": ["object not found"]},
+					{"url": "gs://source/ndt/b.tgz", "errorDetails": ["object not found", "retried"]}
+				]
+			},
+			{
+				"errorCode": "PERMISSION_DENIED",
+				"errorCount": "1",
+				"errorLogEntries": [
+					{"url": "gs://source/host/c.tgz", "errorDetails": ["access denied"]}
+				]
+			}
+		]
+	}`)
+}
+
+func TestCommand_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		client  *fakeTJ
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "success",
+			client: &fakeTJ{ops: []*storagetransfer.Operation{
+				{Name: "transferOperations/fake", Done: true, Metadata: failedMetadata()},
+			}},
+			want: "transferOperations/fake status:FAILED failed:3 bytesFailed:300 errors:3\n" +
+				"  NOT_FOUND                 count:2\n" +
+				"    gs://source/ndt/a.tgz object not found\n" +
+				"    gs://source/ndt/b.tgz object not found; retried\n" +
+				"  PERMISSION_DENIED         count:1\n" +
+				"    gs://source/host/c.tgz access denied\n",
+		},
+		{
+			name:    "error-operation",
+			client:  &fakeTJ{opErr: errors.New("fake operation error")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := &bytes.Buffer{}
+			c := &Command{Client: tt.client, Output: output}
+			if err := c.Errors(context.Background(), "transferOperations/fake"); (err != nil) != tt.wantErr {
+				t.Fatalf("Command.Errors() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if output.String() != tt.want {
+				t.Errorf("Command.Errors() output = %q, want %q", output.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestCommand_ListOperations_errorBreakdowns(t *testing.T) {
+	output := &bytes.Buffer{}
+	c := &Command{
+		Output: output,
+		Format: stctl.FormatJSON,
+		Client: &fakeTJ{listOpsResp: &storagetransfer.ListOperationsResponse{
+			Operations: []*storagetransfer.Operation{{Name: "transferOperations/fake", Metadata: failedMetadata()}},
+		}},
+	}
+	if err := c.ListOperations(context.Background(), "transferJobs/fake"); err != nil {
+		t.Fatalf("Command.ListOperations() error = %v", err)
+	}
+	got := []struct {
+		Job                      string
+		ObjectsDeletedFromSource int64
+		ObjectsDeletedFromSink   int64
+		Errors                   int64
+		ErrorBreakdowns          []struct {
+			Code    string
+			Count   int64
+			Samples []string
+		}
+	}{}
+	if err := json.Unmarshal(output.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Job != "transferJobs/fake" || got[0].Errors != 3 ||
+		got[0].ObjectsDeletedFromSource != 5 || got[0].ObjectsDeletedFromSink != 2 {
+		t.Fatalf("Command.ListOperations() wrong operation: %s", output.String())
+	}
+	if len(got[0].ErrorBreakdowns) != 2 || got[0].ErrorBreakdowns[0].Code != "NOT_FOUND" ||
+		len(got[0].ErrorBreakdowns[0].Samples) != 2 || got[0].ErrorBreakdowns[1].Samples[0] != "gs://source/host/c.tgz" {
+		t.Errorf("Command.ListOperations() wrong error breakdowns: %s", output.String())
+	}
+}
@@ -1,11 +1,22 @@
 package stctl
 
+import "time"
+
 // These aliases allow unit tests in stctl_test package to access unexported items.
 type JobMetadata = jobMetadata
 
 var (
 	ErrNotFound = errNotFound
 	GetDesc     = getDesc
+	GetKeyDesc  = getKeyDesc
 	Find        = (*Command).find
 	SpecMatches = (*Command).specMatches
+
+	TransferStatus       = transferStatus
+	TransferUpdateErrors = transferUpdateErrors
 )
+
+// SetPollInterval sets the delay between checks of a running operation.
+func SetPollInterval(d time.Duration) {
+	pollInterval = d
+}
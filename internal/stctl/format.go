@@ -0,0 +1,261 @@
+package stctl
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"google.golang.org/api/storagetransfer/v1"
+)
+
+// Output formats supported by ListJobs and ListOperations.
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+	FormatCSV   = "csv"
+	FormatYAML  = "yaml"
+)
+
+// Sort orders supported by ListJobs and ListOperations.
+const (
+	SortNone  = ""
+	SortName  = "name"
+	SortStart = "start"
+)
+
+// jobRecord is the formatted representation of a transfer job.
+type jobRecord struct {
+	Name                string   `json:"name" yaml:"name"`
+	Description         string   `json:"description" yaml:"description"`
+	Status              string   `json:"status" yaml:"status"`
+	Created             string   `json:"created" yaml:"created"`
+	Start               string   `json:"start" yaml:"start"`
+	Interval            string   `json:"interval" yaml:"interval"`
+	Source              string   `json:"source" yaml:"source"`
+	Sink                string   `json:"sink" yaml:"sink"`
+	Include             []string `json:"include" yaml:"include"`
+	Exclude             []string `json:"exclude" yaml:"exclude"`
+	MinFileAge          string   `json:"minFileAge" yaml:"minFileAge"`
+	MaxFileAge          string   `json:"maxFileAge" yaml:"maxFileAge"`
+	ModifiedAfter       string   `json:"modifiedAfter" yaml:"modifiedAfter"`
+	ModifiedBefore      string   `json:"modifiedBefore" yaml:"modifiedBefore"`
+	DeleteAfterTransfer bool     `json:"deleteAfterTransfer" yaml:"deleteAfterTransfer"`
+}
+
+var jobHeader = []string{
+	"NAME", "STATUS", "START", "INTERVAL", "SOURCE", "SINK", "INCLUDE", "EXCLUDE",
+	"MIN_AGE", "MAX_AGE", "MODIFIED_AFTER", "MODIFIED_BEFORE", "DELETE", "CREATED", "DESCRIPTION",
+}
+
+func (r *jobRecord) row() []string {
+	return []string{
+		r.Name, r.Status, r.Start, r.Interval, r.Source, r.Sink, fmtList(r.Include), fmtList(r.Exclude),
+		r.MinFileAge, r.MaxFileAge, r.ModifiedAfter, r.ModifiedBefore,
+		strconv.FormatBool(r.DeleteAfterTransfer), r.Created, r.Description,
+	}
+}
+
+func newJobRecord(job *storagetransfer.TransferJob) *jobRecord {
+	r := &jobRecord{
+		Name:        job.Name,
+		Description: job.Description,
+		Status:      job.Status,
+		Created:     job.CreationTime,
+	}
+	if job.Schedule != nil {
+		if job.Schedule.StartTimeOfDay != nil {
+			r.Start = fmtTime(job.Schedule.StartTimeOfDay)
+		}
+		r.Interval = job.Schedule.RepeatInterval
+	}
+	if spec := job.TransferSpec; spec != nil {
+		r.Source = bucketName(spec.GcsDataSource)
+		r.Sink = bucketName(spec.GcsDataSink)
+		if cond := spec.ObjectConditions; cond != nil {
+			r.Include = cond.IncludePrefixes
+			r.Exclude = cond.ExcludePrefixes
+			r.MinFileAge = fmtAge(cond.MinTimeElapsedSinceLastModification)
+			r.MaxFileAge = fmtAge(cond.MaxTimeElapsedSinceLastModification)
+			r.ModifiedAfter = cond.LastModifiedSince
+			r.ModifiedBefore = cond.LastModifiedBefore
+		}
+		r.DeleteAfterTransfer = spec.TransferOptions != nil && spec.TransferOptions.DeleteObjectsFromSourceAfterTransfer
+	}
+	return r
+}
+
+// operationRecord is the formatted representation of a transfer operation.
+type operationRecord struct {
+	Name           string    `json:"name" yaml:"name"`
+	Job            string    `json:"job" yaml:"job"`
+	Status         string    `json:"status" yaml:"status"`
+	Start          time.Time `json:"start" yaml:"start"`
+	End            time.Time `json:"end" yaml:"end"`
+	Duration       string    `json:"duration" yaml:"duration"`
+	Source         string    `json:"source" yaml:"source"`
+	Sink           string    `json:"sink" yaml:"sink"`
+	Include        []string  `json:"include" yaml:"include"`
+	Exclude        []string  `json:"exclude" yaml:"exclude"`
+	ObjectsFound   int64     `json:"objectsFound" yaml:"objectsFound"`
+	ObjectsCopied  int64     `json:"objectsCopied" yaml:"objectsCopied"`
+	ObjectsSkipped int64     `json:"objectsSkipped" yaml:"objectsSkipped"`
+	ObjectsFailed  int64     `json:"objectsFailed" yaml:"objectsFailed"`
+	BytesFound     int64     `json:"bytesFound" yaml:"bytesFound"`
+	BytesCopied    int64     `json:"bytesCopied" yaml:"bytesCopied"`
+	BytesSkipped   int64     `json:"bytesSkipped" yaml:"bytesSkipped"`
+	BytesFailed    int64     `json:"bytesFailed" yaml:"bytesFailed"`
+	// Objects deleted from the source after transfer, or from the sink to
+	// match the source.
+	ObjectsDeletedFromSource int64 `json:"objectsDeletedFromSource" yaml:"objectsDeletedFromSource"`
+	BytesDeletedFromSource   int64 `json:"bytesDeletedFromSource" yaml:"bytesDeletedFromSource"`
+	ObjectsDeletedFromSink   int64 `json:"objectsDeletedFromSink" yaml:"objectsDeletedFromSink"`
+	BytesDeletedFromSink     int64 `json:"bytesDeletedFromSink" yaml:"bytesDeletedFromSink"`
+	ObjectsFailedToDelete    int64 `json:"objectsFailedToDelete" yaml:"objectsFailedToDelete"`
+
+	Errors          int64         `json:"errors" yaml:"errors"`
+	ErrorBreakdowns []errorRecord `json:"errorBreakdowns" yaml:"errorBreakdowns"`
+}
+
+// errorRecord is the formatted representation of the errors of one error code.
+type errorRecord struct {
+	Code  string `json:"code" yaml:"code"`
+	Count int64  `json:"count" yaml:"count"`
+	// Samples are URLs of some of the failed objects.
+	Samples []string `json:"samples" yaml:"samples"`
+}
+
+var operationHeader = []string{
+	"NAME", "JOB", "STATUS", "START", "DURATION", "SOURCE", "SINK", "INCLUDE", "EXCLUDE",
+	"FOUND", "COPIED", "SKIPPED", "FAILED", "BYTES_FOUND", "BYTES_COPIED", "BYTES_SKIPPED", "BYTES_FAILED",
+	"DELETED_FROM_SOURCE", "DELETED_FROM_SINK", "FAILED_TO_DELETE", "ERRORS", "ERROR_CODES",
+}
+
+func (r *operationRecord) row() []string {
+	n := func(i int64) string { return strconv.FormatInt(i, 10) }
+	codes := []string{}
+	for _, e := range r.ErrorBreakdowns {
+		codes = append(codes, fmt.Sprintf("%s:%d", e.Code, e.Count))
+	}
+	return []string{
+		r.Name, r.Job, r.Status, r.Start.Format(time.RFC3339), r.Duration, r.Source, r.Sink,
+		fmtList(r.Include), fmtList(r.Exclude),
+		n(r.ObjectsFound), n(r.ObjectsCopied), n(r.ObjectsSkipped), n(r.ObjectsFailed),
+		n(r.BytesFound), n(r.BytesCopied), n(r.BytesSkipped), n(r.BytesFailed),
+		n(r.ObjectsDeletedFromSource), n(r.ObjectsDeletedFromSink), n(r.ObjectsFailedToDelete),
+		n(r.Errors), fmtList(codes),
+	}
+}
+
+func newOperationRecord(name string, m *jobMetadata) *operationRecord {
+	n := parseCount
+	r := &operationRecord{
+		Name:           name,
+		Job:            m.TransferJobName,
+		Status:         m.Status,
+		Start:          m.Start,
+		End:            m.End,
+		ObjectsFound:   n(m.Counters.ObjectsFound),
+		ObjectsCopied:  n(m.Counters.ObjectsCopied),
+		ObjectsSkipped: n(m.Counters.ObjectsFromSourceSkippedBySync),
+		ObjectsFailed:  n(m.Counters.ObjectsFromSourceFailed),
+		BytesFound:     n(m.Counters.BytesFound),
+		BytesCopied:    n(m.Counters.BytesCopied),
+		BytesSkipped:   n(m.Counters.BytesFromSourceSkippedBySync),
+		BytesFailed:    n(m.Counters.BytesFromSourceFailed),
+
+		ObjectsDeletedFromSource: n(m.Counters.ObjectsDeletedFromSource),
+		BytesDeletedFromSource:   n(m.Counters.BytesDeletedFromSource),
+		ObjectsDeletedFromSink:   n(m.Counters.ObjectsDeletedFromSink),
+		BytesDeletedFromSink:     n(m.Counters.BytesDeletedFromSink),
+		ObjectsFailedToDelete:    n(m.Counters.ObjectsFailedToDeleteFromSink),
+
+		ErrorBreakdowns: []errorRecord{},
+	}
+	if !m.End.IsZero() {
+		r.Duration = m.End.Sub(m.Start).Round(time.Second).String()
+	}
+	if spec := m.TransferSpec; spec != nil {
+		r.Source = bucketName(spec.GcsDataSource)
+		r.Sink = bucketName(spec.GcsDataSink)
+		if cond := spec.ObjectConditions; cond != nil {
+			r.Include = cond.IncludePrefixes
+			r.Exclude = cond.ExcludePrefixes
+		}
+	}
+	for _, e := range m.ErrorBreakdowns {
+		er := errorRecord{Code: e.ErrorCode, Count: n(e.ErrorCount), Samples: []string{}}
+		for _, entry := range e.ErrorLogEntries {
+			er.Samples = append(er.Samples, entry.URL)
+		}
+		r.Errors += er.Count
+		r.ErrorBreakdowns = append(r.ErrorBreakdowns, er)
+	}
+	return r
+}
+
+// fmtList formats a list of prefixes for table and csv columns.
+func fmtList(l []string) string {
+	return strings.Join(l, ",")
+}
+
+// fmtAge formats an elapsed time from the ST API, or returns the empty string
+// if it is unset.
+func fmtAge(elapsed string) string {
+	if elapsed == "" {
+		return ""
+	}
+	return fmtElapsed(elapsed)
+}
+
+// checkFormat returns an error if c.Format or c.SortBy are not supported.
+func (c *Command) checkFormat() error {
+	switch c.Format {
+	case "", FormatTable, FormatJSON, FormatCSV, FormatYAML:
+	default:
+		return fmt.Errorf("unknown format %q", c.Format)
+	}
+	switch c.SortBy {
+	case SortNone, SortName, SortStart:
+	default:
+		return fmt.Errorf("unknown sort order %q", c.SortBy)
+	}
+	return nil
+}
+
+// writeRecords writes records to w in the given format. The header and rows
+// are used by the table and csv formats, and records by json and yaml.
+func writeRecords(w io.Writer, format string, header []string, rows [][]string, records interface{}) error {
+	switch format {
+	case FormatJSON:
+		e := json.NewEncoder(w)
+		e.SetIndent("", "  ")
+		return e.Encode(records)
+	case FormatYAML:
+		e := yaml.NewEncoder(w)
+		defer e.Close()
+		return e.Encode(records)
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+		if err := cw.WriteAll(rows); err != nil {
+			return err
+		}
+		return cw.Error()
+	default:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+		for _, row := range rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	}
+}
@@ -0,0 +1,214 @@
+package stctl_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/m-lab/gcp-config/internal/stctl"
+	"google.golang.org/api/storagetransfer/v1"
+	"gopkg.in/yaml.v3"
+)
+
+func formatJobs() *fakeTJ {
+	job := func(name string, hour int64) *storagetransfer.TransferJob {
+		return &storagetransfer.TransferJob{
+			Name:        name,
+			Description: "STCTL: " + name,
+			Status:      "ENABLED",
+			Schedule: &storagetransfer.Schedule{
+				StartTimeOfDay: &storagetransfer.TimeOfDay{Hours: hour},
+			},
+			TransferSpec: &storagetransfer.TransferSpec{
+				GcsDataSource: &storagetransfer.GcsData{BucketName: "source"},
+				GcsDataSink:   &storagetransfer.GcsData{BucketName: "sink"},
+				ObjectConditions: &storagetransfer.ObjectConditions{
+					IncludePrefixes:                     []string{"ndt", "host"},
+					MinTimeElapsedSinceLastModification: "3600s",
+				},
+			},
+		}
+	}
+	return &fakeTJ{
+		listJobResp: &storagetransfer.ListTransferJobsResponse{
+			TransferJobs: []*storagetransfer.TransferJob{
+				job("transferJobs/b", 1),
+				job("transferJobs/c", 3),
+				job("transferJobs/a", 2),
+			},
+		},
+	}
+}
+
+func formatOperations() *fakeTJ {
+	op := func(name string, start time.Time) *storagetransfer.Operation {
+		m := jobMetadata{
+			TransferSpec: &storagetransfer.TransferSpec{
+				GcsDataSource: &storagetransfer.GcsData{BucketName: "source"},
+				GcsDataSink:   &storagetransfer.GcsData{BucketName: "sink"},
+			},
+			Start:  start,
+			End:    start.Add(90 * time.Second),
+			Status: "SUCCESS",
+		}
+		m.Counters.ObjectsFound = "10"
+		m.Counters.BytesCopied = "2048"
+		return &storagetransfer.Operation{Name: name, Metadata: md2JSON(m)}
+	}
+	start := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	return &fakeTJ{
+		listOpsResp: &storagetransfer.ListOperationsResponse{
+			Operations: []*storagetransfer.Operation{
+				op("transferOperations/a", start.Add(time.Hour)),
+				op("transferOperations/b", start),
+			},
+		},
+	}
+}
+
+func TestCommand_ListJobs_format(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		sortBy string
+		check  func(t *testing.T, out string)
+	}{
+		{
+			name:   "table-sort-name",
+			format: stctl.FormatTable,
+			sortBy: stctl.SortName,
+			check: func(t *testing.T, out string) {
+				lines := strings.Split(strings.TrimSpace(out), "\n")
+				if len(lines) != 4 || !strings.HasPrefix(lines[1], "transferJobs/a") || !strings.HasPrefix(lines[3], "transferJobs/c") {
+					t.Errorf("wrong table:\n%s", out)
+				}
+				// Columns are aligned.
+				col := strings.Index(lines[0], "STATUS")
+				for _, line := range lines[1:] {
+					if strings.Index(line, "ENABLED") != col {
+						t.Errorf("column not aligned:\n%s", out)
+					}
+				}
+			},
+		},
+		{
+			name:   "csv-sort-start",
+			format: stctl.FormatCSV,
+			sortBy: stctl.SortStart,
+			check: func(t *testing.T, out string) {
+				rows, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(rows) != 4 || rows[1][0] != "transferJobs/b" || rows[2][0] != "transferJobs/a" {
+					t.Errorf("wrong csv:\n%s", out)
+				}
+				if rows[1][6] != "ndt,host" || rows[1][8] != "1h0m0s" {
+					t.Errorf("wrong csv conditions:\n%s", out)
+				}
+			},
+		},
+		{
+			name:   "json",
+			format: stctl.FormatJSON,
+			check: func(t *testing.T, out string) {
+				jobs := []map[string]interface{}{}
+				if err := json.Unmarshal([]byte(out), &jobs); err != nil {
+					t.Fatal(err)
+				}
+				if len(jobs) != 3 || jobs[0]["name"] != "transferJobs/b" || jobs[0]["minFileAge"] != "1h0m0s" {
+					t.Errorf("wrong json:\n%s", out)
+				}
+			},
+		},
+		{
+			name:   "yaml",
+			format: stctl.FormatYAML,
+			check: func(t *testing.T, out string) {
+				jobs := []map[string]interface{}{}
+				if err := yaml.Unmarshal([]byte(out), &jobs); err != nil {
+					t.Fatal(err)
+				}
+				if len(jobs) != 3 || jobs[0]["source"] != "source" || jobs[0]["start"] != "01:00:00" {
+					t.Errorf("wrong yaml:\n%s", out)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := &bytes.Buffer{}
+			c := &Command{Client: formatJobs(), Output: output, Format: tt.format, SortBy: tt.sortBy}
+			if err := c.ListJobs(context.Background()); err != nil {
+				t.Fatalf("Command.ListJobs() error = %v", err)
+			}
+			tt.check(t, output.String())
+		})
+	}
+}
+
+func TestCommand_ListOperations_format(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		sortBy string
+		check  func(t *testing.T, out string)
+	}{
+		{
+			name:   "table-sort-start",
+			format: stctl.FormatTable,
+			sortBy: stctl.SortStart,
+			check: func(t *testing.T, out string) {
+				lines := strings.Split(strings.TrimSpace(out), "\n")
+				if len(lines) != 3 || !strings.HasPrefix(lines[1], "transferOperations/b") {
+					t.Errorf("wrong table:\n%s", out)
+				}
+				if !strings.Contains(lines[1], "1m30s") {
+					t.Errorf("missing duration:\n%s", out)
+				}
+			},
+		},
+		{
+			name:   "json",
+			format: stctl.FormatJSON,
+			check: func(t *testing.T, out string) {
+				ops := []map[string]interface{}{}
+				if err := json.Unmarshal([]byte(out), &ops); err != nil {
+					t.Fatal(err)
+				}
+				if len(ops) != 2 || ops[0]["name"] != "transferOperations/a" ||
+					ops[0]["objectsFound"] != 10.0 || ops[0]["bytesCopied"] != 2048.0 {
+					t.Errorf("wrong json:\n%s", out)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := &bytes.Buffer{}
+			c := &Command{Client: formatOperations(), Output: output, Format: tt.format, SortBy: tt.sortBy}
+			if err := c.ListOperations(context.Background(), "transferJobs/fake"); err != nil {
+				t.Fatalf("Command.ListOperations() error = %v", err)
+			}
+			tt.check(t, output.String())
+		})
+	}
+}
+
+func TestCommand_ListJobs_formatErrors(t *testing.T) {
+	for _, c := range []*Command{
+		{Client: formatJobs(), Output: &bytes.Buffer{}, Format: "xml"},
+		{Client: formatJobs(), Output: &bytes.Buffer{}, SortBy: "size"},
+	} {
+		if err := c.ListJobs(context.Background()); err == nil {
+			t.Errorf("Command.ListJobs() expected error for format %q sort %q", c.Format, c.SortBy)
+		}
+		if err := c.ListOperations(context.Background(), "transferJobs/fake"); err == nil {
+			t.Errorf("Command.ListOperations() expected error for format %q sort %q", c.Format, c.SortBy)
+		}
+	}
+}
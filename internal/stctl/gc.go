@@ -0,0 +1,89 @@
+package stctl
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/storagetransfer/v1"
+)
+
+// ParseAge parses a duration like time.ParseDuration, and also accepts a
+// whole number of days, e.g. "90d".
+func ParseAge(s string) (time.Duration, error) {
+	if days := strings.TrimSuffix(s, "d"); days != s {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// GC deletes disabled jobs managed by stctl that have neither started an
+// operation nor been modified, e.g. disabled, within olderThan. Unless confirm
+// is true, GC only reports the jobs it would delete.
+func (c *Command) GC(ctx context.Context, olderThan time.Duration, confirm bool) ([]*storagetransfer.TransferJob, error) {
+	disabled := []*storagetransfer.TransferJob{}
+	visit := func(resp *storagetransfer.ListTransferJobsResponse) error {
+		for _, job := range resp.TransferJobs {
+			if strings.HasPrefix(job.Description, managedPrefix) {
+				disabled = append(disabled, job)
+			}
+		}
+		return nil
+	}
+	if err := c.Client.DisabledJobs(ctx, visit); err != nil {
+		return nil, err
+	}
+	cutoff := time.Now().Add(-olderThan)
+	deleted := []*storagetransfer.TransferJob{}
+	for _, job := range disabled {
+		last, err := c.lastStart(ctx, job.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list operations of %q: %w", job.Name, err)
+		}
+		// A job that never ran is as old as its last modification.
+		if mod, err := time.Parse(time.RFC3339, job.LastModificationTime); err == nil && mod.After(last) {
+			last = mod
+		}
+		if last.After(cutoff) {
+			fmt.Fprintf(c.Output, "recent %-25s last:%s desc:%q\n", job.Name, last.Format(time.RFC3339), job.Description)
+			continue
+		}
+		deleted = append(deleted, job)
+		if !confirm {
+			fmt.Fprintf(c.Output, "would delete %-25s desc:%q\n", job.Name, job.Description)
+			continue
+		}
+		if _, err := c.Delete(ctx, job.Name); err != nil {
+			return nil, fmt.Errorf("failed to delete %q: %w", job.Name, err)
+		}
+		fmt.Fprintf(c.Output, "deleted %-25s desc:%q\n", job.Name, job.Description)
+	}
+	if !confirm {
+		fmt.Fprintf(c.Output, "dry run: would delete:%d; use -confirm to delete\n", len(deleted))
+		return deleted, nil
+	}
+	fmt.Fprintf(c.Output, "deleted:%d\n", len(deleted))
+	return deleted, nil
+}
+
+// lastStart returns the start time of the most recent operation of the named
+// job, or the zero time if the job never ran.
+func (c *Command) lastStart(ctx context.Context, name string) (time.Time, error) {
+	var last time.Time
+	visit := func(r *storagetransfer.ListOperationsResponse) error {
+		for _, op := range r.Operations {
+			if m := parseJobMetadata(op.Metadata); m.Start.After(last) {
+				last = m.Start
+			}
+		}
+		return nil
+	}
+	err := c.Client.Operations(ctx, name, visit)
+	return last, err
+}
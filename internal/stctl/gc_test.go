@@ -0,0 +1,135 @@
+package stctl_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+	"github.com/m-lab/gcp-config/internal/stctl"
+	"google.golang.org/api/storagetransfer/v1"
+)
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		age     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{age: "90d", want: 90 * 24 * time.Hour},
+		{age: "0d", want: 0},
+		{age: "36h", want: 36 * time.Hour},
+		{age: "d", wantErr: true},
+		{age: "-1d", wantErr: true},
+		{age: "1.5d", wantErr: true},
+		{age: "ninety", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.age, func(t *testing.T) {
+			got, err := stctl.ParseAge(tt.age)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAge() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseAge() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommand_GC(t *testing.T) {
+	ops := func(start time.Time) *storagetransfer.ListOperationsResponse {
+		return &storagetransfer.ListOperationsResponse{
+			Operations: []*storagetransfer.Operation{
+				{Name: "transferOperations/old", Metadata: md2JSON(jobMetadata{Start: time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)})},
+				{Name: "transferOperations/last", Metadata: md2JSON(jobMetadata{Start: start})},
+			},
+		}
+	}
+	newClient := func() *fakeTJ {
+		return &fakeTJ{
+			job: &storagetransfer.TransferJob{},
+			disabledJobResp: &storagetransfer.ListTransferJobsResponse{
+				TransferJobs: []*storagetransfer.TransferJob{
+					{Name: "transferJobs/stale", Description: "STCTL: transfer a -> b at 01:00:00", LastModificationTime: "2020-01-02T00:00:00Z"},
+					{Name: "transferJobs/recent", Description: "STCTL: transfer a -> c at 01:00:00", LastModificationTime: "2020-01-02T00:00:00Z"},
+					{Name: "transferJobs/never-ran", Description: "STCTL: transfer a -> d at 01:00:00", LastModificationTime: "2019-01-02T00:00:00Z"},
+					{Name: "transferJobs/recently-disabled", Description: "STCTL: transfer a -> e at 01:00:00", LastModificationTime: time.Now().Add(-time.Hour).Format(time.RFC3339)},
+					{Name: "transferJobs/recently-disabled-stale", Description: "STCTL: transfer a -> f at 01:00:00", LastModificationTime: time.Now().Add(-time.Hour).Format(time.RFC3339)},
+					{Name: "transferJobs/unmanaged", Description: "manual"},
+				},
+			},
+			jobOpsResp: map[string]*storagetransfer.ListOperationsResponse{
+				"transferJobs/stale":                   ops(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)),
+				"transferJobs/recent":                  ops(time.Now().Add(-24 * time.Hour)),
+				"transferJobs/recently-disabled-stale": ops(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)),
+			},
+		}
+	}
+	tests := []struct {
+		name    string
+		client  *fakeTJ
+		confirm bool
+		want    []string
+		output  string
+		wantErr bool
+	}{
+		{
+			name:   "dry-run",
+			client: newClient(),
+			want:   []string{"transferJobs/stale", "transferJobs/never-ran"},
+			output: "dry run: would delete:2",
+		},
+		{
+			name:    "confirm",
+			client:  newClient(),
+			confirm: true,
+			want:    []string{"transferJobs/stale", "transferJobs/never-ran"},
+			output:  "deleted:2",
+		},
+		{
+			name:    "error-list",
+			client:  &fakeTJ{listErr: errors.New("fake list error")},
+			wantErr: true,
+		},
+		{
+			name: "error-delete",
+			client: func() *fakeTJ {
+				f := newClient()
+				f.getErr = errors.New("fake get error")
+				return f
+			}(),
+			confirm: true,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := &bytes.Buffer{}
+			c := &Command{Client: tt.client, Output: output}
+			jobs, err := c.GC(context.Background(), 90*24*time.Hour, tt.confirm)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Command.GC() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got := []string{}
+			for _, job := range jobs {
+				got = append(got, job.Name)
+			}
+			if diff := deep.Equal(got, tt.want); diff != nil {
+				t.Errorf("Command.GC() got diff %v", diff)
+			}
+			if !strings.Contains(output.String(), tt.output) {
+				t.Errorf("Command.GC() missing %q in output:\n%s", tt.output, output.String())
+			}
+			if tt.confirm && tt.client.job.Status != "DELETED" {
+				t.Errorf("Command.GC() did not delete jobs; status %q", tt.client.job.Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package stctl
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/api/storagetransfer/v1"
+)
+
+// Pause pauses the named running operation.
+func (c *Command) Pause(ctx context.Context, name string) error {
+	if err := c.Client.Pause(ctx, name); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.Output, "%-9s %s\n", "paused", name)
+	return nil
+}
+
+// Resume resumes the named paused operation.
+func (c *Command) Resume(ctx context.Context, name string) error {
+	if err := c.Client.Resume(ctx, name); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.Output, "%-9s %s\n", "resumed", name)
+	return nil
+}
+
+// Cancel cancels the named operation. Objects already transferred, or deleted
+// from the source, are not restored.
+func (c *Command) Cancel(ctx context.Context, name string) error {
+	if err := c.Client.Cancel(ctx, name); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.Output, "%-9s %s\n", "cancelled", name)
+	return nil
+}
+
+// CancelAll cancels every operation of the named job that is not yet done and
+// returns the names of the cancelled operations.
+func (c *Command) CancelAll(ctx context.Context, job string) ([]string, error) {
+	running := []string{}
+	visit := func(r *storagetransfer.ListOperationsResponse) error {
+		for _, op := range r.Operations {
+			if !op.Done {
+				running = append(running, op.Name)
+			}
+		}
+		return nil
+	}
+	if err := c.Client.Operations(ctx, job, visit); err != nil {
+		return nil, err
+	}
+	for i, name := range running {
+		if err := c.Cancel(ctx, name); err != nil {
+			return running[:i], fmt.Errorf("failed to cancel %q: %w", name, err)
+		}
+	}
+	fmt.Fprintf(c.Output, "cancelled:%d\n", len(running))
+	return running, nil
+}
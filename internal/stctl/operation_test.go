@@ -0,0 +1,115 @@
+package stctl_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-test/deep"
+	"google.golang.org/api/storagetransfer/v1"
+)
+
+func TestCommand_Control(t *testing.T) {
+	tests := []struct {
+		name    string
+		control func(c *Command) func(ctx context.Context, name string) error
+		err     error
+		want    string
+	}{
+		{
+			name:    "pause",
+			control: func(c *Command) func(ctx context.Context, name string) error { return c.Pause },
+			want:    "paused    transferOperations/fake-op\n",
+		},
+		{
+			name:    "resume",
+			control: func(c *Command) func(ctx context.Context, name string) error { return c.Resume },
+			want:    "resumed   transferOperations/fake-op\n",
+		},
+		{
+			name:    "cancel",
+			control: func(c *Command) func(ctx context.Context, name string) error { return c.Cancel },
+			want:    "cancelled transferOperations/fake-op\n",
+		},
+		{
+			name:    "error-pause",
+			control: func(c *Command) func(ctx context.Context, name string) error { return c.Pause },
+			err:     errors.New("fake pause error"),
+		},
+		{
+			name:    "error-resume",
+			control: func(c *Command) func(ctx context.Context, name string) error { return c.Resume },
+			err:     errors.New("fake resume error"),
+		},
+		{
+			name:    "error-cancel",
+			control: func(c *Command) func(ctx context.Context, name string) error { return c.Cancel },
+			err:     errors.New("fake cancel error"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := &bytes.Buffer{}
+			f := &fakeTJ{controlErr: tt.err}
+			c := &Command{Client: f, Output: output}
+			err := tt.control(c)(context.Background(), "transferOperations/fake-op")
+			if err != tt.err {
+				t.Errorf("Command.%s() error = %v, want %v", tt.name, err, tt.err)
+			}
+			if output.String() != tt.want {
+				t.Errorf("Command.%s() output = %q, want %q", tt.name, output.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestCommand_CancelAll(t *testing.T) {
+	ops := &storagetransfer.ListOperationsResponse{
+		Operations: []*storagetransfer.Operation{
+			{Name: "transferOperations/running"},
+			{Name: "transferOperations/done", Done: true},
+			{Name: "transferOperations/paused"},
+		},
+	}
+	tests := []struct {
+		name    string
+		client  *fakeTJ
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "success",
+			client: &fakeTJ{listOpsResp: ops},
+			want:   []string{"transferOperations/running", "transferOperations/paused"},
+		},
+		{
+			name:    "error-list",
+			client:  &fakeTJ{listErr: errors.New("fake list error")},
+			wantErr: true,
+		},
+		{
+			name:    "error-cancel",
+			client:  &fakeTJ{listOpsResp: ops, controlErr: errors.New("fake cancel error")},
+			want:    []string{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Command{Client: tt.client, Output: &bytes.Buffer{}}
+			got, err := c.CancelAll(context.Background(), "transferJobs/fake-job")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Command.CancelAll() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := deep.Equal(got, tt.want); diff != nil {
+				t.Errorf("Command.CancelAll() got diff %v", diff)
+			}
+			if tt.client.controlErr == nil {
+				if diff := deep.Equal(tt.client.controlled, tt.want); diff != nil {
+					t.Errorf("Command.CancelAll() cancelled diff %v", diff)
+				}
+			}
+		})
+	}
+}
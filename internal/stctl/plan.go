@@ -0,0 +1,96 @@
+package stctl
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/api/storagetransfer/v1"
+)
+
+// PlanAction describes the action Sync would take.
+type PlanAction string
+
+// Actions reported by Plan.
+const (
+	PlanCreate  = PlanAction("create")
+	PlanUpdate  = PlanAction("update")
+	PlanReplace = PlanAction("replace")
+	PlanNoop    = PlanAction("noop")
+)
+
+// Plan describes the changes Sync would make for a single job.
+type Plan struct {
+	// Name is the name of the existing job, if any.
+	Name        string
+	Description string
+	Action      PlanAction
+	Diffs       []FieldDiff
+}
+
+// Pending reports whether applying the plan would change any job.
+func (p *Plan) Pending() bool {
+	return p.Action != PlanNoop
+}
+
+// Plan reports the action Sync would take for the current command parameters
+// and the fields that differ from the existing job, without changing any job.
+func (c *Command) Plan(ctx context.Context) (*Plan, error) {
+	found, err := c.find(ctx)
+	if err != errNotFound && err != nil {
+		return nil, err
+	}
+	p := &Plan{
+		Description: c.description(),
+		Action:      PlanCreate,
+	}
+	if found == nil {
+		// Compare to an empty job to report every configured field.
+		p.Diffs = c.specDiff(&storagetransfer.TransferJob{})
+	} else {
+		p.Name = found.Name
+		p.Diffs = c.specDiff(found)
+		if c.unadopted(found) {
+			// Sync adopts the legacy job before any other change.
+			p.Diffs = append([]FieldDiff{{Field: "key", Desired: c.Key}}, p.Diffs...)
+		}
+		switch {
+		case len(p.Diffs) == 0:
+			p.Action = PlanNoop
+		case scheduleChanged(p.Diffs):
+			p.Action = PlanReplace
+		default:
+			p.Action = PlanUpdate
+		}
+	}
+	fmt.Fprintf(c.Output, "%-7s %-25s desc:%q\n", p.Action, p.Name, p.Description)
+	for _, d := range p.Diffs {
+		fmt.Fprintf(c.Output, "  %s: %q -> %q\n", d.Field, d.Current, d.Desired)
+	}
+	return p, nil
+}
+
+// PlanConfig reports the actions Apply would take for every job in cfg
+// selected for c.Project.
+func (c *Command) PlanConfig(ctx context.Context, cfg *Config) ([]*Plan, error) {
+	plans := []*Plan{}
+	counts := map[PlanAction]int{}
+	for i := range cfg.Jobs {
+		j := &cfg.Jobs[i]
+		if !j.selected(c.Project) {
+			continue
+		}
+		jc, err := c.forJob(j)
+		if err != nil {
+			return nil, err
+		}
+		p, err := jc.Plan(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to plan %q: %w", jc.description(), err)
+		}
+		plans = append(plans, p)
+		counts[p.Action]++
+	}
+	fmt.Fprintf(c.Output, "noop:%d update:%d replace:%d create:%d\n",
+		counts[PlanNoop], counts[PlanUpdate], counts[PlanReplace], counts[PlanCreate])
+	return plans, nil
+}
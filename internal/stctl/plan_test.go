@@ -0,0 +1,170 @@
+package stctl_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+	"github.com/m-lab/gcp-config/internal/stctl"
+	"github.com/m-lab/go/flagx"
+	storagetransfer "google.golang.org/api/storagetransfer/v1"
+)
+
+func TestCommand_Plan(t *testing.T) {
+	start := flagx.Time{Hour: 1, Minute: 2, Second: 3}
+	existing := &storagetransfer.TransferJob{
+		Name:        "transferJobs/existing",
+		Description: getDesc("fake-source", "fake-target", start),
+		Schedule: &storagetransfer.Schedule{
+			StartTimeOfDay: &storagetransfer.TimeOfDay{Hours: 1, Minutes: 2, Seconds: 3},
+			RepeatInterval: "86400s",
+		},
+		TransferSpec: &storagetransfer.TransferSpec{
+			ObjectConditions: &storagetransfer.ObjectConditions{
+				IncludePrefixes:                     []string{"a"},
+				MinTimeElapsedSinceLastModification: "3600s",
+			},
+		},
+	}
+	list := &storagetransfer.ListTransferJobsResponse{
+		TransferJobs: []*storagetransfer.TransferJob{existing},
+	}
+	tests := []struct {
+		name    string
+		c       *Command
+		want    *stctl.Plan
+		wantErr bool
+	}{
+		{
+			name: "noop",
+			c: &Command{
+				Client:       &fakeTJ{listJobResp: list},
+				SourceBucket: "fake-source",
+				TargetBucket: "fake-target",
+				StartTime:    start,
+				Interval:     "86400s",
+				Prefixes:     []string{"a"},
+				MinFileAge:   time.Hour,
+			},
+			want: &stctl.Plan{
+				Name:        "transferJobs/existing",
+				Description: getDesc("fake-source", "fake-target", start),
+				Action:      stctl.PlanNoop,
+				Diffs:       []stctl.FieldDiff{},
+			},
+		},
+		{
+			name: "update",
+			c: &Command{
+				Client:       &fakeTJ{listJobResp: list},
+				SourceBucket: "fake-source",
+				TargetBucket: "fake-target",
+				StartTime:    start,
+				Prefixes:     []string{"a"},
+				MinFileAge:   2 * time.Hour,
+			},
+			want: &stctl.Plan{
+				Name:        "transferJobs/existing",
+				Description: getDesc("fake-source", "fake-target", start),
+				Action:      stctl.PlanUpdate,
+				Diffs: []stctl.FieldDiff{
+					{Field: "minFileAge", Current: "1h0m0s", Desired: "2h0m0s"},
+				},
+			},
+		},
+		{
+			name: "replace",
+			c: &Command{
+				Client:              &fakeTJ{listJobResp: list},
+				SourceBucket:        "fake-source",
+				TargetBucket:        "fake-target",
+				StartTime:           start,
+				Interval:            "3600s",
+				Prefixes:            []string{"a", "b"},
+				MaxFileAge:          120 * time.Hour,
+				DeleteAfterTransfer: true,
+			},
+			want: &stctl.Plan{
+				Name:        "transferJobs/existing",
+				Description: getDesc("fake-source", "fake-target", start),
+				Action:      stctl.PlanReplace,
+				Diffs: []stctl.FieldDiff{
+					{Field: "interval", Current: "86400s", Desired: "3600s"},
+					{Field: "include", Current: "[a]", Desired: "[a b]"},
+					{Field: "minFileAge", Current: "1h0m0s", Desired: "0s"},
+					{Field: "maxFileAge", Current: "0s", Desired: "120h0m0s"},
+					{Field: "deleteAfterTransfer", Current: "false", Desired: "true"},
+				},
+			},
+		},
+		{
+			name: "create",
+			c: &Command{
+				Client:       &fakeTJ{listJobResp: list},
+				SourceBucket: "fake-source",
+				TargetBucket: "new-target",
+				StartTime:    start,
+				Prefixes:     []string{"a"},
+			},
+			want: &stctl.Plan{
+				Description: getDesc("fake-source", "new-target", start),
+				Action:      stctl.PlanCreate,
+				Diffs: []stctl.FieldDiff{
+					{Field: "time", Current: "", Desired: "01:02:03"},
+					{Field: "include", Current: "[]", Desired: "[a]"},
+				},
+			},
+		},
+		{
+			name: "error-list",
+			c: &Command{
+				Client: &fakeTJ{listErr: errors.New("fake list error")},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.c.Output = &bytes.Buffer{}
+			got, err := tt.c.Plan(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Command.Plan() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := deep.Equal(got, tt.want); diff != nil {
+				t.Errorf("Command.Plan() did not match expected;\n%s", strings.Join(diff, "\n"))
+			}
+			if got != nil && got.Pending() != (tt.want.Action != stctl.PlanNoop) {
+				t.Errorf("Plan.Pending() = %t for action %q", got.Pending(), got.Action)
+			}
+		})
+	}
+}
+
+func TestCommand_PlanConfig(t *testing.T) {
+	output := &bytes.Buffer{}
+	c := &Command{
+		Client:  &fakeTJ{listJobResp: &storagetransfer.ListTransferJobsResponse{}},
+		Project: "mlab-sandbox",
+		Output:  output,
+	}
+	cfg := &stctl.Config{
+		Jobs: []stctl.JobConfig{
+			{Source: "a", Target: "b", Time: "01:00:00"},
+			{Source: "c", Target: "d", Time: "01:00:00", Projects: []string{"mlab-oti"}},
+		},
+	}
+	plans, err := c.PlanConfig(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Command.PlanConfig() unexpected error = %v", err)
+	}
+	if len(plans) != 1 || plans[0].Action != stctl.PlanCreate {
+		t.Errorf("Command.PlanConfig() wrong plans; got %v", plans)
+	}
+	if !strings.HasSuffix(output.String(), "noop:0 update:0 replace:0 create:1\n") {
+		t.Errorf("Command.PlanConfig() wrong summary; got %q", output.String())
+	}
+}
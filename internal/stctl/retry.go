@@ -0,0 +1,117 @@
+package stctl
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/m-lab/go/logx"
+	"github.com/m-lab/go/pretty"
+
+	"google.golang.org/api/storagetransfer/v1"
+)
+
+// retryPrefix is the description prefix of one-time jobs created by
+// RetryFailed. Unlike managedPrefix, these jobs are not managed by sync,
+// apply, prune, doctor, or check.
+const retryPrefix = "stctl retry-failed"
+
+// failedObjectNames returns the sorted, unique names of objects in the source
+// bucket that the operation reported as failed, and the total number of
+// failed objects. The API only reports a sample of failed objects, so the
+// total may be larger than the number of names.
+func failedObjectNames(m *jobMetadata) ([]string, int64) {
+	prefix := "gs://" + bucketName(m.TransferSpec.GcsDataSource) + "/"
+	seen := map[string]bool{}
+	names := []string{}
+	var total int64
+	for _, e := range m.ErrorBreakdowns {
+		total += parseCount(e.ErrorCount)
+		for _, entry := range e.ErrorLogEntries {
+			name := strings.TrimPrefix(entry.URL, prefix)
+			if name == entry.URL || name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, total
+}
+
+// RetryFailed creates a one-time job that transfers only the objects that the
+// named operation failed to transfer. The object names are written as a
+// transfer manifest to the given "gs://<bucket>/<object>" location. The new
+// job copies the source, sink, and transfer options of the operation, but
+// not its object conditions, so that objects are retried even if they are
+// now older than the original maxFileAge.
+func (c *Command) RetryFailed(ctx context.Context, name, manifest string) (*storagetransfer.TransferJob, error) {
+	bucket, object, ok := strings.Cut(strings.TrimPrefix(manifest, "gs://"), "/")
+	if !strings.HasPrefix(manifest, "gs://") || !ok || bucket == "" || object == "" {
+		return nil, fmt.Errorf("manifest %q is not of the form gs://<bucket>/<object>", manifest)
+	}
+	op, err := c.Client.Operation(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	m := parseJobMetadata(op.Metadata)
+	if m.TransferSpec == nil || m.TransferSpec.GcsDataSource == nil {
+		return nil, fmt.Errorf("operation %q has no GCS source", name)
+	}
+	names, total := failedObjectNames(m)
+	if len(names) == 0 {
+		return nil, fmt.Errorf("operation %q reports no failed objects", name)
+	}
+	if total > int64(len(names)) {
+		fmt.Fprintf(c.Output, "warning: only %d of %d failed objects are reported by the API\n", len(names), total)
+	}
+
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	for _, n := range names {
+		w.Write([]string{n})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	if err := c.Objects.Write(ctx, bucket, object, buf.Bytes()); err != nil {
+		return nil, fmt.Errorf("failed to write manifest %q: %w", manifest, err)
+	}
+
+	ts := time.Now().UTC()
+	today := &storagetransfer.Date{
+		Day:   int64(ts.Day()),
+		Month: int64(ts.Month()),
+		Year:  int64(ts.Year()),
+	}
+	create := &storagetransfer.TransferJob{
+		Description: fmt.Sprintf("%s %s", retryPrefix, name),
+		ProjectId:   c.Project,
+		Schedule: &storagetransfer.Schedule{
+			// One-time jobs have equal start and end dates. Without a
+			// StartTimeOfDay, the job runs immediately.
+			ScheduleStartDate: today,
+			ScheduleEndDate:   today,
+		},
+		Status: "ENABLED",
+		TransferSpec: &storagetransfer.TransferSpec{
+			GcsDataSource:    m.TransferSpec.GcsDataSource,
+			GcsDataSink:      m.TransferSpec.GcsDataSink,
+			TransferOptions:  m.TransferSpec.TransferOptions,
+			TransferManifest: &storagetransfer.TransferManifest{Location: manifest},
+		},
+	}
+	logx.Debug.Print(pretty.Sprint(create))
+	job, err := c.Client.Create(ctx, create)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(c.Output, "%-9s %-25s objects:%d manifest:%s\n", "created", job.Name, len(names), manifest)
+	return job, nil
+}
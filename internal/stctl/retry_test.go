@@ -0,0 +1,123 @@
+package stctl_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+	"google.golang.org/api/storagetransfer/v1"
+)
+
+type fakeObjects struct {
+	written map[string]string
+	err     error
+}
+
+func (f *fakeObjects) Write(ctx context.Context, bucket, name string, content []byte) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.written["gs://"+bucket+"/"+name] = string(content)
+	return nil
+}
+
+func TestCommand_RetryFailed(t *testing.T) {
+	failed := &storagetransfer.Operation{Name: "transferOperations/fake", Done: true, Metadata: failedMetadata()}
+	tests := []struct {
+		name      string
+		client    *fakeTJ
+		objects   *fakeObjects
+		manifest  string
+		want      string
+		wantError bool
+	}{
+		{
+			name:     "success",
+			client:   &fakeTJ{ops: []*storagetransfer.Operation{failed}},
+			objects:  &fakeObjects{written: map[string]string{}},
+			manifest: "gs://manifests/retry.csv",
+			want:     "host/c.tgz\nndt/a.tgz\nndt/b.tgz\n",
+		},
+		{
+			name: "error-no-failed-objects",
+			client: &fakeTJ{ops: []*storagetransfer.Operation{{
+				Name:     "transferOperations/fake",
+				Metadata: md2JSON(jobMetadata{TransferSpec: &storagetransfer.TransferSpec{GcsDataSource: &storagetransfer.GcsData{BucketName: "source"}}}),
+			}}},
+			objects:   &fakeObjects{written: map[string]string{}},
+			manifest:  "gs://manifests/retry.csv",
+			wantError: true,
+		},
+		{
+			name:      "error-no-source",
+			client:    &fakeTJ{ops: []*storagetransfer.Operation{{Name: "transferOperations/fake"}}},
+			objects:   &fakeObjects{written: map[string]string{}},
+			manifest:  "gs://manifests/retry.csv",
+			wantError: true,
+		},
+		{
+			name:      "error-manifest-location",
+			client:    &fakeTJ{ops: []*storagetransfer.Operation{failed}},
+			objects:   &fakeObjects{written: map[string]string{}},
+			manifest:  "manifests/retry.csv",
+			wantError: true,
+		},
+		{
+			name:      "error-operation",
+			client:    &fakeTJ{opErr: errors.New("fake operation error")},
+			objects:   &fakeObjects{written: map[string]string{}},
+			manifest:  "gs://manifests/retry.csv",
+			wantError: true,
+		},
+		{
+			name:      "error-write",
+			client:    &fakeTJ{ops: []*storagetransfer.Operation{failed}},
+			objects:   &fakeObjects{err: errors.New("fake write error")},
+			manifest:  "gs://manifests/retry.csv",
+			wantError: true,
+		},
+		{
+			name:      "error-create",
+			client:    &fakeTJ{ops: []*storagetransfer.Operation{failed}, createErr: errors.New("fake create error")},
+			objects:   &fakeObjects{written: map[string]string{}},
+			manifest:  "gs://manifests/retry.csv",
+			wantError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := &bytes.Buffer{}
+			c := &Command{Client: tt.client, Objects: tt.objects, Output: output, Project: "fake-mlab-testing"}
+			job, err := c.RetryFailed(context.Background(), "transferOperations/fake", tt.manifest)
+			if (err != nil) != tt.wantError {
+				t.Fatalf("Command.RetryFailed() error = %v, wantError %v", err, tt.wantError)
+			}
+			if tt.wantError {
+				return
+			}
+			if got := tt.objects.written[tt.manifest]; got != tt.want {
+				t.Errorf("Command.RetryFailed() manifest = %q, want %q", got, tt.want)
+			}
+			wantSpec := &storagetransfer.TransferSpec{
+				GcsDataSource:    &storagetransfer.GcsData{BucketName: "source"},
+				TransferManifest: &storagetransfer.TransferManifest{Location: tt.manifest},
+			}
+			if diff := deep.Equal(job.TransferSpec, wantSpec); diff != nil {
+				t.Errorf("Command.RetryFailed() wrong spec; %v", diff)
+			}
+			s := job.Schedule
+			if deep.Equal(s.ScheduleStartDate, s.ScheduleEndDate) != nil || s.ScheduleEndDate == nil || s.StartTimeOfDay != nil {
+				t.Errorf("Command.RetryFailed() job is not one-time; %#v", s)
+			}
+			if strings.HasPrefix(job.Description, "STCTL:") {
+				t.Errorf("Command.RetryFailed() job must not be managed; %q", job.Description)
+			}
+			if !strings.Contains(output.String(), "objects:3") {
+				t.Errorf("Command.RetryFailed() wrong output: %q", output.String())
+			}
+		})
+	}
+}
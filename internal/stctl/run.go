@@ -0,0 +1,101 @@
+package stctl
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/m-lab/go/logx"
+	"github.com/stephen-soltesz/pretty"
+
+	"google.golang.org/api/storagetransfer/v1"
+)
+
+// pollInterval is the delay between checks of a running operation.
+var pollInterval = 30 * time.Second
+
+// Run starts an immediate transfer operation for the named job, in addition to
+// the job's regular schedule. When wait is true, Run blocks until the
+// operation is done, like Wait. Run returns the latest state of the operation.
+func (c *Command) Run(ctx context.Context, name string, wait bool) (*storagetransfer.Operation, error) {
+	op, err := c.Client.Run(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	logx.Debug.Print(pretty.Sprint(op))
+	fmt.Fprintf(c.Output, "%-9s %-25s job:%s\n", "started", op.Name, name)
+	if !wait {
+		return op, nil
+	}
+	return c.Wait(ctx, op.Name)
+}
+
+// Wait polls the named operation until it is done or ctx is cancelled,
+// printing progress whenever the operation counters or status change. Wait
+// returns an error unless the operation finished with status SUCCESS.
+func (c *Command) Wait(ctx context.Context, name string) (*storagetransfer.Operation, error) {
+	last := ""
+	for {
+		op, err := c.Client.Operation(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		m := parseJobMetadata(op.Metadata)
+		progress := fmt.Sprintf("found:%d copied:%d skipped:%d failed:%d bytes:%d status:%s",
+			parseCount(m.Counters.ObjectsFound), parseCount(m.Counters.ObjectsCopied),
+			parseCount(m.Counters.ObjectsFromSourceSkippedBySync), parseCount(m.Counters.ObjectsFromSourceFailed),
+			parseCount(m.Counters.BytesCopied), m.Status)
+		if progress != last {
+			fmt.Fprintf(c.Output, "%-9s %-25s %s\n", "progress", op.Name, progress)
+			last = progress
+		}
+		if op.Done {
+			if op.Error != nil {
+				return op, fmt.Errorf("operation %q failed: %s", op.Name, op.Error.Message)
+			}
+			if m.Status != "SUCCESS" {
+				return op, fmt.Errorf("operation %q finished with status %s", op.Name, m.Status)
+			}
+			return op, nil
+		}
+		select {
+		case <-ctx.Done():
+			return op, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// Match returns the name of the single enabled job that transfers from the
+// source to the target bucket given as "<source>-><target>".
+func (c *Command) Match(ctx context.Context, match string) (string, error) {
+	buckets := strings.Split(match, "->")
+	if len(buckets) != 2 || buckets[0] == "" || buckets[1] == "" {
+		return "", fmt.Errorf("match %q is not of the form <source>-><target>", match)
+	}
+	names := []string{}
+	visit := func(resp *storagetransfer.ListTransferJobsResponse) error {
+		for _, job := range resp.TransferJobs {
+			if job.TransferSpec == nil {
+				continue
+			}
+			if bucketName(job.TransferSpec.GcsDataSource) == buckets[0] &&
+				bucketName(job.TransferSpec.GcsDataSink) == buckets[1] {
+				names = append(names, job.Name)
+			}
+		}
+		return nil
+	}
+	if err := c.Client.Jobs(ctx, visit); err != nil {
+		return "", err
+	}
+	switch len(names) {
+	case 0:
+		return "", fmt.Errorf("%w: %q", errNotFound, match)
+	case 1:
+		return names[0], nil
+	default:
+		return "", fmt.Errorf("match %q is ambiguous: %v", match, names)
+	}
+}
@@ -0,0 +1,235 @@
+package stctl_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/m-lab/gcp-config/internal/stctl"
+	"google.golang.org/api/storagetransfer/v1"
+)
+
+func TestCommand_Run(t *testing.T) {
+	stctl.SetPollInterval(time.Millisecond)
+	running := &storagetransfer.Operation{Name: "transferOperations/fake-run"}
+	done := &storagetransfer.Operation{
+		Name:     "transferOperations/fake-run",
+		Done:     true,
+		Metadata: md2JSON(jobMetadata{Status: "SUCCESS"}),
+	}
+	tests := []struct {
+		name     string
+		client   *fakeTJ
+		wait     bool
+		wantDone bool
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:   "success",
+			client: &fakeTJ{},
+			want:   "started   transferOperations/transferJobs/fake-job-fake-run job:transferJobs/fake-job",
+		},
+		{
+			name:     "success-wait",
+			client:   &fakeTJ{ops: []*storagetransfer.Operation{running, running, done}},
+			wait:     true,
+			wantDone: true,
+			want:     "status:SUCCESS",
+		},
+		{
+			name:    "error-run",
+			client:  &fakeTJ{runErr: errors.New("fake run error")},
+			wantErr: true,
+		},
+		{
+			name:    "error-operation",
+			client:  &fakeTJ{opErr: errors.New("fake operation error")},
+			wait:    true,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := &bytes.Buffer{}
+			c := &Command{Client: tt.client, Output: output}
+			op, err := c.Run(context.Background(), "transferJobs/fake-job", tt.wait)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Command.Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if op.Done != tt.wantDone {
+				t.Errorf("Command.Run() done = %t, want %t", op.Done, tt.wantDone)
+			}
+			if !strings.Contains(output.String(), tt.want) {
+				t.Errorf("Command.Run() missing %q in output:\n%s", tt.want, output.String())
+			}
+		})
+	}
+}
+
+func TestCommand_Run_cancelled(t *testing.T) {
+	stctl.SetPollInterval(time.Hour)
+	running := &storagetransfer.Operation{Name: "transferOperations/fake-run"}
+	c := &Command{Client: &fakeTJ{ops: []*storagetransfer.Operation{running}}, Output: &bytes.Buffer{}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.Run(ctx, "transferJobs/fake-job", true); err != context.Canceled {
+		t.Errorf("Command.Run() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestCommand_Match(t *testing.T) {
+	job := func(name, src, dest string) *storagetransfer.TransferJob {
+		return &storagetransfer.TransferJob{
+			Name: name,
+			TransferSpec: &storagetransfer.TransferSpec{
+				GcsDataSource: &storagetransfer.GcsData{BucketName: src},
+				GcsDataSink:   &storagetransfer.GcsData{BucketName: dest},
+			},
+		}
+	}
+	client := &fakeTJ{
+		listJobResp: &storagetransfer.ListTransferJobsResponse{
+			TransferJobs: []*storagetransfer.TransferJob{
+				job("transferJobs/1", "a", "b"),
+				job("transferJobs/2", "a", "c"),
+				job("transferJobs/3", "a", "c"),
+				{Name: "transferJobs/no-spec"},
+			},
+		},
+	}
+	tests := []struct {
+		name    string
+		match   string
+		want    string
+		listErr error
+		wantErr error
+	}{
+		{
+			name:  "success",
+			match: "a->b",
+			want:  "transferJobs/1",
+		},
+		{
+			name:    "error-not-found",
+			match:   "b->a",
+			wantErr: stctl.ErrNotFound,
+		},
+		{
+			name:  "error-ambiguous",
+			match: "a->c",
+		},
+		{
+			name:  "error-format",
+			match: "a-b",
+		},
+		{
+			name:    "error-list",
+			match:   "a->b",
+			listErr: errors.New("fake list error"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client.listErr = tt.listErr
+			c := &Command{Client: client}
+			got, err := c.Match(context.Background(), tt.match)
+			if (err != nil) != (tt.want == "") {
+				t.Fatalf("Command.Match() error = %v, want %q", err, tt.want)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Command.Match() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Command.Match() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommand_Wait(t *testing.T) {
+	stctl.SetPollInterval(time.Millisecond)
+	op := func(done bool, status, copied string) *storagetransfer.Operation {
+		m := jobMetadata{Status: status}
+		m.Counters.ObjectsFound = "10"
+		m.Counters.ObjectsCopied = copied
+		return &storagetransfer.Operation{
+			Name:     "transferOperations/fake-op",
+			Done:     done,
+			Metadata: md2JSON(m),
+		}
+	}
+	tests := []struct {
+		name      string
+		ops       []*storagetransfer.Operation
+		timeout   time.Duration
+		wantLines int
+		wantErr   bool
+	}{
+		{
+			name: "success",
+			ops: []*storagetransfer.Operation{
+				op(false, "IN_PROGRESS", ""),
+				op(false, "IN_PROGRESS", ""),
+				op(false, "IN_PROGRESS", "5"),
+				op(true, "SUCCESS", "10"),
+			},
+			wantLines: 3,
+		},
+		{
+			name:      "error-failed",
+			ops:       []*storagetransfer.Operation{op(true, "FAILED", "")},
+			wantLines: 1,
+			wantErr:   true,
+		},
+		{
+			name:      "error-aborted",
+			ops:       []*storagetransfer.Operation{op(false, "IN_PROGRESS", ""), op(true, "ABORTED", "")},
+			wantLines: 2,
+			wantErr:   true,
+		},
+		{
+			name: "error-operation-error",
+			ops: []*storagetransfer.Operation{
+				{Name: "transferOperations/fake-op", Done: true, Error: &storagetransfer.Status{Message: "fake error"}},
+			},
+			wantLines: 1,
+			wantErr:   true,
+		},
+		{
+			name:      "error-timeout",
+			ops:       []*storagetransfer.Operation{op(false, "IN_PROGRESS", "")},
+			timeout:   20 * time.Millisecond,
+			wantLines: 1,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.timeout != 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+			output := &bytes.Buffer{}
+			c := &Command{Client: &fakeTJ{ops: tt.ops}, Output: output}
+			got, err := c.Wait(ctx, "transferOperations/fake-op")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Command.Wait() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got == nil {
+				t.Errorf("Command.Wait() returned nil operation")
+			}
+			if n := strings.Count(output.String(), "\n"); n != tt.wantLines {
+				t.Errorf("Command.Wait() wrote wrong number of lines; got %d, want %d:\n%s", n, tt.wantLines, output.String())
+			}
+		})
+	}
+}
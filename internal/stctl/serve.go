@@ -0,0 +1,160 @@
+package stctl
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"google.golang.org/api/storagetransfer/v1"
+)
+
+// Metrics describe the most recent finished operation of each managed job.
+var (
+	jobLabels = []string{"description", "source", "sink"}
+
+	transferObjects = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "stctl_transfer_objects",
+			Help: "Objects found, copied, skipped or failed by the last operation of a job.",
+		},
+		append([]string{"state"}, jobLabels...),
+	)
+	transferBytes = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "stctl_transfer_bytes",
+			Help: "Bytes copied by the last operation of a job.",
+		},
+		jobLabels,
+	)
+	transferDuration = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "stctl_transfer_duration_seconds",
+			Help: "Duration of the last operation of a job.",
+		},
+		jobLabels,
+	)
+	transferLastSuccess = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "stctl_transfer_last_success_timestamp_seconds",
+			Help: "Start time of the last successful operation of a job.",
+		},
+		jobLabels,
+	)
+	transferStatus = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "stctl_transfer_status",
+			Help: "Status of the last operation of a job. The current status is 1.",
+		},
+		append([]string{"status"}, jobLabels...),
+	)
+	transferUpdateErrors = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "stctl_transfer_update_errors_total",
+			Help: "Number of failures to list jobs or operations for metrics.",
+		},
+	)
+)
+
+// operationStatuses are the possible statuses of a transfer operation.
+var operationStatuses = []string{"IN_PROGRESS", "PAUSED", "SUCCESS", "FAILED", "ABORTED", "QUEUED"}
+
+// jobMetrics are the metric values of a single managed job.
+type jobMetrics struct {
+	labels      []string
+	last        *jobMetadata
+	lastSuccess time.Time
+}
+
+// Serve updates the transfer metrics every interval until ctx is cancelled.
+// Failed updates are logged and counted, and keep the previous values.
+func (c *Command) Serve(ctx context.Context, interval time.Duration) error {
+	for {
+		if err := c.UpdateMetrics(ctx); err != nil {
+			log.Printf("Failed to update metrics: %v", err)
+			transferUpdateErrors.Inc()
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// UpdateMetrics lists managed jobs and their operations, and replaces all
+// transfer metrics with the values from the most recent finished operation of
+// each job.
+func (c *Command) UpdateMetrics(ctx context.Context) error {
+	jobs := []*storagetransfer.TransferJob{}
+	visit := func(resp *storagetransfer.ListTransferJobsResponse) error {
+		for _, job := range resp.TransferJobs {
+			if strings.HasPrefix(job.Description, managedPrefix) {
+				jobs = append(jobs, job)
+			}
+		}
+		return nil
+	}
+	if err := c.Client.Jobs(ctx, visit); err != nil {
+		return err
+	}
+	metrics := []*jobMetrics{}
+	for _, job := range jobs {
+		m, err := c.jobMetrics(ctx, job)
+		if err != nil {
+			return err
+		}
+		metrics = append(metrics, m)
+	}
+
+	// Only reset after all values are known, so that failures keep the
+	// previous values and removed jobs do not leave stale series.
+	for _, vec := range []*prometheus.GaugeVec{transferObjects, transferBytes, transferDuration, transferLastSuccess, transferStatus} {
+		vec.Reset()
+	}
+	for _, m := range metrics {
+		if !m.lastSuccess.IsZero() {
+			transferLastSuccess.WithLabelValues(m.labels...).Set(float64(m.lastSuccess.Unix()))
+		}
+		if m.last == nil {
+			continue
+		}
+		counters := map[string]string{
+			"found":   m.last.Counters.ObjectsFound,
+			"copied":  m.last.Counters.ObjectsCopied,
+			"skipped": m.last.Counters.ObjectsFromSourceSkippedBySync,
+			"failed":  m.last.Counters.ObjectsFromSourceFailed,
+		}
+		for state, value := range counters {
+			transferObjects.WithLabelValues(append([]string{state}, m.labels...)...).Set(float64(parseCount(value)))
+		}
+		transferBytes.WithLabelValues(m.labels...).Set(float64(parseCount(m.last.Counters.BytesCopied)))
+		transferDuration.WithLabelValues(m.labels...).Set(m.last.End.Sub(m.last.Start).Seconds())
+		for _, status := range operationStatuses {
+			value := 0.0
+			if status == m.last.Status {
+				value = 1
+			}
+			transferStatus.WithLabelValues(append([]string{status}, m.labels...)...).Set(value)
+		}
+	}
+	return nil
+}
+
+// jobMetrics finds the most recent finished and successful operations of job.
+func (c *Command) jobMetrics(ctx context.Context, job *storagetransfer.TransferJob) (*jobMetrics, error) {
+	m := &jobMetrics{labels: []string{job.Description, "", ""}}
+	if job.TransferSpec != nil {
+		m.labels[1] = bucketName(job.TransferSpec.GcsDataSource)
+		m.labels[2] = bucketName(job.TransferSpec.GcsDataSink)
+	}
+	var err error
+	m.last, m.lastSuccess, err = c.lastFinished(ctx, job.Name)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
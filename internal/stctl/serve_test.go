@@ -0,0 +1,102 @@
+package stctl_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/m-lab/gcp-config/internal/stctl"
+	"github.com/m-lab/go/prometheusx/promtest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/api/storagetransfer/v1"
+)
+
+func TestCommand_UpdateMetrics(t *testing.T) {
+	start := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	op := func(done bool, start time.Time, status string) *storagetransfer.Operation {
+		m := jobMetadata{Start: start, End: start.Add(time.Minute), Status: status}
+		m.Counters.ObjectsFound = "10"
+		m.Counters.ObjectsCopied = "7"
+		m.Counters.ObjectsFromSourceSkippedBySync = "2"
+		m.Counters.ObjectsFromSourceFailed = "1"
+		m.Counters.BytesCopied = "1024"
+		return &storagetransfer.Operation{Done: done, Metadata: md2JSON(m)}
+	}
+	c := &Command{
+		Output: &bytes.Buffer{},
+		Client: &fakeTJ{
+			listJobResp: &storagetransfer.ListTransferJobsResponse{
+				TransferJobs: []*storagetransfer.TransferJob{
+					{
+						Name:        "transferJobs/1",
+						Description: "STCTL: transfer a -> b at 01:00:00",
+						TransferSpec: &storagetransfer.TransferSpec{
+							GcsDataSource: &storagetransfer.GcsData{BucketName: "a"},
+							GcsDataSink:   &storagetransfer.GcsData{BucketName: "b"},
+						},
+					},
+					{Name: "transferJobs/unmanaged", Description: "manual"},
+				},
+			},
+			jobOpsResp: map[string]*storagetransfer.ListOperationsResponse{
+				"transferJobs/1": {
+					Operations: []*storagetransfer.Operation{
+						op(true, start, "SUCCESS"),
+						op(true, start.Add(24*time.Hour), "FAILED"),
+						op(false, start.Add(48*time.Hour), "IN_PROGRESS"),
+					},
+				},
+			},
+		},
+	}
+	if err := c.UpdateMetrics(context.Background()); err != nil {
+		t.Fatalf("Command.UpdateMetrics() error = %v", err)
+	}
+	labels := `description="STCTL: transfer a -> b at 01:00:00",sink="b",source="a"`
+	expected := `
+# HELP stctl_transfer_bytes Bytes copied by the last operation of a job.
+# TYPE stctl_transfer_bytes gauge
+stctl_transfer_bytes{` + labels + `} 1024
+# HELP stctl_transfer_duration_seconds Duration of the last operation of a job.
+# TYPE stctl_transfer_duration_seconds gauge
+stctl_transfer_duration_seconds{` + labels + `} 60
+# HELP stctl_transfer_last_success_timestamp_seconds Start time of the last successful operation of a job.
+# TYPE stctl_transfer_last_success_timestamp_seconds gauge
+stctl_transfer_last_success_timestamp_seconds{` + labels + `} 1.5463008e+09
+# HELP stctl_transfer_objects Objects found, copied, skipped or failed by the last operation of a job.
+# TYPE stctl_transfer_objects gauge
+stctl_transfer_objects{` + labels + `,state="copied"} 7
+stctl_transfer_objects{` + labels + `,state="failed"} 1
+stctl_transfer_objects{` + labels + `,state="found"} 10
+stctl_transfer_objects{` + labels + `,state="skipped"} 2
+`
+	err := testutil.GatherAndCompare(prometheus.DefaultGatherer, strings.NewReader(expected),
+		"stctl_transfer_bytes", "stctl_transfer_duration_seconds",
+		"stctl_transfer_last_success_timestamp_seconds", "stctl_transfer_objects")
+	if err != nil {
+		t.Error(err)
+	}
+	for status, want := range map[string]float64{"FAILED": 1, "SUCCESS": 0, "IN_PROGRESS": 0} {
+		got := testutil.ToFloat64(stctl.TransferStatus.WithLabelValues(status, "STCTL: transfer a -> b at 01:00:00", "a", "b"))
+		if got != want {
+			t.Errorf("Command.UpdateMetrics() status %s = %f, want %f", status, got, want)
+		}
+	}
+	promtest.LintMetrics(t)
+}
+
+func TestCommand_Serve(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	c := &Command{Client: &fakeTJ{listErr: errors.New("fake list error")}, Output: &bytes.Buffer{}}
+	if err := c.Serve(ctx, time.Millisecond); err != context.DeadlineExceeded {
+		t.Errorf("Command.Serve() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if n := testutil.ToFloat64(stctl.TransferUpdateErrors); n < 1 {
+		t.Errorf("Command.Serve() update errors = %f, want > 0", n)
+	}
+}
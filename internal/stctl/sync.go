@@ -3,6 +3,7 @@ package stctl
 import (
 	"context"
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/m-lab/go/flagx"
@@ -18,66 +19,161 @@ var (
 	errNotFound = fmt.Errorf("no matching job found")
 )
 
-// find searches the Client for a TransferJob with matching Description.
-// The returned TransferJob may or may not match the rest of the job spec.
+// find searches the Client for a TransferJob with matching identity, i.e. Key
+// or Description. When no job has the key, find returns the job with the
+// legacy description of a keyed job, if any, so that it may be adopted rather
+// than duplicated. The returned TransferJob may or may not match the rest of
+// the job spec.
 func (c *Command) find(ctx context.Context) (*storagetransfer.TransferJob, error) {
-	var found *storagetransfer.TransferJob
+	var found, legacy *storagetransfer.TransferJob
 
-	// Generate canonical description from current config.
-	desc := getDesc(c.SourceBucket, c.TargetBucket, c.StartTime)
+	// Generate canonical identity from current config.
+	id := c.identity()
+	legacyID := getDesc(c.SourceBucket, c.TargetBucket, c.StartTime)
 
-	// List jobs and find first that matches canonical description.
+	// List jobs and find first that matches canonical identity.
 	logx.Debug.Println("Listing jobs")
 	findJob := func(resp *storagetransfer.ListTransferJobsResponse) error {
 		for _, job := range resp.TransferJobs {
-			if job.Schedule == nil || job.Schedule.ScheduleEndDate != nil {
+			if found != nil || job.Schedule == nil || job.Schedule.ScheduleEndDate != nil {
 				// We only manage jobs without an end date.
 				continue
 			}
 			logx.Debug.Print(pretty.Sprint(job))
-			if desc == job.Description {
+			if id == jobIdentity(job.Description) {
 				// Sync depends on the convention for storage transfer job management that
 				// each job has a unique description, so the first
-				// matching job should be the only matching job.
+				// matching job should be the only matching job. Use Doctor to
+				// find and resolve duplicates.
 				found = job
-				return nil
+			}
+			if c.Key != "" && legacy == nil && legacyID == job.Description {
+				legacy = job
 			}
 		}
-		// Job was not found.
-		return errNotFound
+		return nil
+	}
+
+	if err := c.Client.Jobs(ctx, findJob); err != nil {
+		return nil, err
 	}
+	if found == nil {
+		found = legacy
+	}
+	if found == nil {
+		// Job was not found on any page.
+		return nil, errNotFound
+	}
+	return found, nil
+}
 
-	err := c.Client.Jobs(ctx, findJob)
-	return found, err
+// unadopted reports whether job is the legacy job of a keyed job that has not
+// been adopted yet.
+func (c *Command) unadopted(job *storagetransfer.TransferJob) bool {
+	return c.Key != "" && jobIdentity(job.Description) != c.identity()
 }
 
+// SyncAction describes the action taken by Sync.
+type SyncAction string
+
+// Actions taken by Sync.
+const (
+	ActionUnchanged = SyncAction("unchanged")
+	ActionUpdated   = SyncAction("updated")
+	ActionReplaced  = SyncAction("replaced")
+	ActionCreated   = SyncAction("created")
+)
+
 // Sync guarantees that a job exists matching the current command parameters. If
 // a job with matching command parameters already exists, no action is taken. If
-// a matching description is found with different values for IncludePrefixes or
-// StartTimeOfDay, then the original job is disabled and a new job created. In
-// either case, the found or newly created job is returned on success.
+// a matching description is found with a different TransferSpec, e.g.
+// IncludePrefixes, then the job is updated in place. If the schedule differs,
+// e.g. StartTimeOfDay, then the original job is disabled and a new job created,
+// since the API does not allow updating a job's schedule. In every case, the
+// found, updated, or newly created job is returned on success.
 func (c *Command) Sync(ctx context.Context) (*storagetransfer.TransferJob, error) {
+	job, _, err := c.sync(ctx)
+	return job, err
+}
+
+// sync implements Sync and also returns the action taken.
+func (c *Command) sync(ctx context.Context) (*storagetransfer.TransferJob, SyncAction, error) {
 	found, err := c.find(ctx)
 	if err != errNotFound && err != nil {
-		return nil, err
+		return nil, "", err
 	}
+	action := ActionCreated
 	if found != nil {
 		logx.Debug.Println("Found job!")
 		logx.Debug.Print(pretty.Sprint(found))
-		if c.specMatches(found) {
+		unchanged := ActionUnchanged
+		if c.unadopted(found) {
+			// Adopt the legacy job so that it is not duplicated by a new keyed job.
+			log.Printf("Adopting job %q for key %q", found.Name, c.Key)
+			found, err = c.adopt(ctx, found)
+			if err != nil {
+				return nil, "", err
+			}
+			unchanged = ActionUpdated
+		}
+		diffs := c.specDiff(found)
+		if len(diffs) == 0 {
 			// We found a matching job, do nothing, return success.
 			logx.Debug.Println("Specs match!")
-			return found, nil
+			return found, unchanged, nil
+		}
+		if !scheduleChanged(diffs) {
+			log.Printf("Updating job %q in place: %v", found.Name, diffs)
+			job, err := c.updateSpec(ctx, found)
+			if err != nil {
+				return nil, "", err
+			}
+			return job, ActionUpdated, nil
 		}
-		// We found a managed job and it does not match the new spec, so disable it.
+		// We found a managed job and its schedule does not match the new spec, so disable it.
+		log.Printf("Replacing job %q for schedule change: %v", found.Name, diffs)
 		_, err := c.Disable(ctx, found.Name)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
+		action = ActionReplaced
 	}
 	// Create new job matching the preferred spec.
 	logx.Debug.Println("Creating new job!")
-	return c.Create(ctx)
+	job, err := c.Create(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	return job, action, nil
+}
+
+// scheduleChanged reports whether any diff is to a schedule field, which the
+// API does not allow updating.
+func scheduleChanged(diffs []FieldDiff) bool {
+	for _, d := range diffs {
+		if d.Field == "time" || d.Field == "interval" {
+			return true
+		}
+	}
+	return false
+}
+
+// updateSpec replaces the TransferSpec of the existing job with the current
+// command parameters.
+func (c *Command) updateSpec(ctx context.Context, current *storagetransfer.TransferJob) (*storagetransfer.TransferJob, error) {
+	spec := c.getSpec()
+	update := &storagetransfer.UpdateTransferJobRequest{
+		ProjectId: c.Project,
+		// MUST: only set three fields: `Description`, `TransferSpec`, and `Status`.
+		TransferJob: &storagetransfer.TransferJob{
+			Description:  c.description(),
+			TransferSpec: &spec,
+			Status:       current.Status,
+		},
+		UpdateTransferJobFieldMask: "description,transfer_spec",
+	}
+	logx.Debug.Print(pretty.Sprint(update))
+	return c.Client.Update(ctx, current.Name, update)
 }
 
 // Verify that the two times are equal.
@@ -100,41 +196,90 @@ func includesEqual(configured []string, desired []string) bool {
 	return true
 }
 
-func (c *Command) specMatches(job *storagetransfer.TransferJob) bool {
-	if job.Schedule.StartTimeOfDay == nil ||
-		!timesEqual(job.Schedule.StartTimeOfDay, c.StartTime) {
-		logx.Debug.Println("spec: times not equal", job.Schedule, c.StartTime)
-		return false
+// FieldDiff describes a job field that differs from the desired spec. Field
+// names match the corresponding stctl flags.
+type FieldDiff struct {
+	Field   string
+	Current string
+	Desired string
+}
+
+// specDiff returns the fields of job that differ from the current command
+// parameters.
+func (c *Command) specDiff(job *storagetransfer.TransferJob) []FieldDiff {
+	diffs := []FieldDiff{}
+	add := func(field, current, desired string) {
+		diffs = append(diffs, FieldDiff{Field: field, Current: current, Desired: desired})
+	}
+	schedule := job.Schedule
+	if schedule == nil {
+		schedule = &storagetransfer.Schedule{}
+	}
+	if schedule.StartTimeOfDay == nil {
+		add("time", "", c.StartTime.String())
+	} else if !timesEqual(schedule.StartTimeOfDay, c.StartTime) {
+		add("time", fmtTime(schedule.StartTimeOfDay), c.StartTime.String())
 	}
 
-	if c.Interval != "" && job.Schedule.RepeatInterval != c.Interval {
-		logx.Debug.Println("spec: interval not equal", job.Schedule.RepeatInterval, c.Interval)
-		return false
+	if c.Interval != "" && schedule.RepeatInterval != c.Interval {
+		add("interval", schedule.RepeatInterval, c.Interval)
 	}
 
-	cond := job.TransferSpec.ObjectConditions
-	if cond == nil {
-		if len(c.Prefixes) > 0 || c.MaxFileAge > 0 || c.MinFileAge > 0 {
-			logx.Debug.Println("spec: conditions not equal", cond, c.Prefixes, c.MaxFileAge, c.MinFileAge)
-			return false
+	spec := job.TransferSpec
+	if spec == nil {
+		spec = &storagetransfer.TransferSpec{}
+	}
+	if c.Key != "" {
+		// Only keyed jobs may change source or target buckets. Otherwise, they
+		// are part of the description and already match.
+		if src := bucketName(spec.GcsDataSource); src != c.SourceBucket {
+			add("source", src, c.SourceBucket)
 		}
-	} else if !includesEqual(cond.IncludePrefixes, c.Prefixes) ||
-		!durationsMatch(c.MaxFileAge, cond.MaxTimeElapsedSinceLastModification) ||
-		!durationsMatch(c.MinFileAge, cond.MinTimeElapsedSinceLastModification) {
-		logx.Debug.Println("spec: conditions not equal",
-			cond.IncludePrefixes, c.Prefixes,
-			cond.MaxTimeElapsedSinceLastModification, c.MaxFileAge.String(),
-			cond.MinTimeElapsedSinceLastModification, c.MinFileAge.String())
-		return false
+		if target := bucketName(spec.GcsDataSink); target != c.TargetBucket {
+			add("target", target, c.TargetBucket)
+		}
+	}
+	cond := spec.ObjectConditions
+	if cond == nil {
+		cond = &storagetransfer.ObjectConditions{}
+	}
+	if !includesEqual(cond.IncludePrefixes, c.Prefixes) {
+		add("include", fmt.Sprintf("%v", cond.IncludePrefixes), fmt.Sprintf("%v", c.Prefixes))
+	}
+	if !durationsMatch(c.MinFileAge, cond.MinTimeElapsedSinceLastModification) {
+		add("minFileAge", fmtElapsed(cond.MinTimeElapsedSinceLastModification), c.MinFileAge.String())
+	}
+	if !durationsMatch(c.MaxFileAge, cond.MaxTimeElapsedSinceLastModification) {
+		add("maxFileAge", fmtElapsed(cond.MaxTimeElapsedSinceLastModification), c.MaxFileAge.String())
 	}
 
-	jobDeleteOption := job.TransferSpec.TransferOptions != nil && job.TransferSpec.TransferOptions.DeleteObjectsFromSourceAfterTransfer
+	jobDeleteOption := spec.TransferOptions != nil && spec.TransferOptions.DeleteObjectsFromSourceAfterTransfer
 	if c.DeleteAfterTransfer != jobDeleteOption {
-		logx.Debug.Println("spec: delete after transfer not equal", jobDeleteOption, c.DeleteAfterTransfer)
-		return false
+		add("deleteAfterTransfer", fmt.Sprintf("%t", jobDeleteOption), fmt.Sprintf("%t", c.DeleteAfterTransfer))
 	}
+	return diffs
+}
 
-	return true
+func (c *Command) specMatches(job *storagetransfer.TransferJob) bool {
+	diffs := c.specDiff(job)
+	for _, d := range diffs {
+		logx.Debug.Printf("spec: %s not equal: %q != %q", d.Field, d.Current, d.Desired)
+	}
+	return len(diffs) == 0
+}
+
+func bucketName(d *storagetransfer.GcsData) string {
+	if d == nil {
+		return ""
+	}
+	return d.BucketName
+}
+
+// fmtElapsed formats an elapsed time from the ST API like a time.Duration.
+func fmtElapsed(elapsed string) string {
+	// Accept that an error parsing correctly means zero seconds.
+	d, _ := time.ParseDuration(elapsed)
+	return d.String()
 }
 
 // Convert the string based times from the ST API to numbers to make comparisons trivial.
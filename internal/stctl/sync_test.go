@@ -92,7 +92,7 @@ func TestCommand_Sync(t *testing.T) {
 			},
 		},
 		{
-			name: "success-disable-and-create",
+			name: "success-update-in-place",
 			c: &Command{
 				SourceBucket: "fake-source",
 				TargetBucket: "fake-target",
@@ -117,22 +117,14 @@ func TestCommand_Sync(t *testing.T) {
 							},
 						},
 					},
-					// a fake job that is disabled.
-					job: &storagetransfer.TransferJob{},
+					// a fake job that is updated in place.
+					job: &storagetransfer.TransferJob{Name: "transferOperations/description-matches-ObjectConditions-does-not"},
 				},
 			},
 			shouldFind: true,
 			expected: &storagetransfer.TransferJob{
 				Description: "STCTL: transfer fake-source -> fake-target at 01:02:03",
-				Name:        "THIS-IS-A-FAKE-ASSIGNED-JOB-NAME",
-				Schedule: &storagetransfer.Schedule{
-					StartTimeOfDay: &storagetransfer.TimeOfDay{Hours: 1, Minutes: 2, Seconds: 3},
-					ScheduleStartDate: &storagetransfer.Date{
-						Day:   int64(ts.Day()),
-						Month: int64(ts.Month()),
-						Year:  int64(ts.Year()),
-					},
-				},
+				Name:        "transferOperations/description-matches-ObjectConditions-does-not",
 				TransferSpec: &storagetransfer.TransferSpec{
 					GcsDataSource: &storagetransfer.GcsData{BucketName: "fake-source"},
 					GcsDataSink:   &storagetransfer.GcsData{BucketName: "fake-target"},
@@ -140,11 +132,10 @@ func TestCommand_Sync(t *testing.T) {
 						IncludePrefixes: []string{"a", "b"},
 					},
 				},
-				Status: "ENABLED",
 			},
 		},
 		{
-			name: "success-delete-mismatch-disable-and-create",
+			name: "success-delete-mismatch-update-in-place",
 			c: &Command{
 				SourceBucket:        "fake-source",
 				TargetBucket:        "fake-target",
@@ -168,22 +159,14 @@ func TestCommand_Sync(t *testing.T) {
 							},
 						},
 					},
-					// a fake job that is disabled.
-					job: &storagetransfer.TransferJob{},
+					// a fake job that is updated in place.
+					job: &storagetransfer.TransferJob{Name: "transferOperations/description-matches-delete-option-does-not"},
 				},
 			},
 			shouldFind: true,
 			expected: &storagetransfer.TransferJob{
 				Description: "STCTL: transfer fake-source -> fake-target at 01:02:03",
-				Name:        "THIS-IS-A-FAKE-ASSIGNED-JOB-NAME",
-				Schedule: &storagetransfer.Schedule{
-					StartTimeOfDay: &storagetransfer.TimeOfDay{Hours: 1, Minutes: 2, Seconds: 3},
-					ScheduleStartDate: &storagetransfer.Date{
-						Day:   int64(ts.Day()),
-						Month: int64(ts.Month()),
-						Year:  int64(ts.Year()),
-					},
-				},
+				Name:        "transferOperations/description-matches-delete-option-does-not",
 				TransferSpec: &storagetransfer.TransferSpec{
 					GcsDataSource: &storagetransfer.GcsData{BucketName: "fake-source"},
 					GcsDataSink:   &storagetransfer.GcsData{BucketName: "fake-target"},
@@ -191,11 +174,10 @@ func TestCommand_Sync(t *testing.T) {
 						DeleteObjectsFromSourceAfterTransfer: true,
 					},
 				},
-				Status: "ENABLED",
 			},
 		},
 		{
-			name: "success-nil-object-cond-disable-and-create",
+			name: "success-nil-object-cond-update-in-place",
 			c: &Command{
 				SourceBucket: "fake-source",
 				TargetBucket: "fake-target",
@@ -219,22 +201,14 @@ func TestCommand_Sync(t *testing.T) {
 							},
 						},
 					},
-					// a fake job that is disabled.
-					job: &storagetransfer.TransferJob{},
+					// a fake job that is updated in place.
+					job: &storagetransfer.TransferJob{Name: "transferOperations/nil-object-cond"},
 				},
 			},
 			shouldFind: true,
 			expected: &storagetransfer.TransferJob{
 				Description: "STCTL: transfer fake-source -> fake-target at 01:02:03",
-				Name:        "THIS-IS-A-FAKE-ASSIGNED-JOB-NAME",
-				Schedule: &storagetransfer.Schedule{
-					StartTimeOfDay: &storagetransfer.TimeOfDay{Hours: 1, Minutes: 2, Seconds: 3},
-					ScheduleStartDate: &storagetransfer.Date{
-						Day:   int64(ts.Day()),
-						Month: int64(ts.Month()),
-						Year:  int64(ts.Year()),
-					},
-				},
+				Name:        "transferOperations/nil-object-cond",
 				TransferSpec: &storagetransfer.TransferSpec{
 					GcsDataSource: &storagetransfer.GcsData{BucketName: "fake-source"},
 					GcsDataSink:   &storagetransfer.GcsData{BucketName: "fake-target"},
@@ -242,7 +216,6 @@ func TestCommand_Sync(t *testing.T) {
 						IncludePrefixes: []string{"a", "b"},
 					},
 				},
-				Status: "ENABLED",
 			},
 		},
 		{
@@ -331,7 +304,7 @@ func TestCommand_Sync(t *testing.T) {
 			wantErr: true,
 		},
 		{
-			name: "error-found-and-disable-error-different-IncludePrefixes",
+			name: "error-found-and-update-error-different-IncludePrefixes",
 			c: &Command{
 				SourceBucket: "fake-source",
 				TargetBucket: "fake-target",
@@ -356,7 +329,7 @@ func TestCommand_Sync(t *testing.T) {
 							},
 						},
 					},
-					getErr: errors.New("fake get error causes Disable() to fail"),
+					updateErr: errors.New("fake update error causes update in place to fail"),
 				},
 			},
 			shouldFind: true,
@@ -47,9 +47,18 @@ type filter struct {
 
 // Jobs calls `visit` on all ENABLED transfer jobs in the current project.
 func (j *Job) Jobs(ctx context.Context, visit func(resp *storagetransfer.ListTransferJobsResponse) error) error {
+	return j.list(ctx, "ENABLED", visit)
+}
+
+// DisabledJobs calls `visit` on all DISABLED transfer jobs in the current project.
+func (j *Job) DisabledJobs(ctx context.Context, visit func(resp *storagetransfer.ListTransferJobsResponse) error) error {
+	return j.list(ctx, "DISABLED", visit)
+}
+
+func (j *Job) list(ctx context.Context, status string, visit func(resp *storagetransfer.ListTransferJobsResponse) error) error {
 	f := filter{
 		Project:  j.project,
-		Statuses: []string{"ENABLED"},
+		Statuses: []string{status},
 	}
 	bfilter, _ := json.Marshal(&f)
 	list := j.service.TransferJobs.List(string(bfilter)).PageSize(20)
@@ -80,3 +89,32 @@ func (j *Job) Operations(ctx context.Context, name string, visit func(r *storage
 	bfilter, _ := json.Marshal(&f)
 	return j.service.TransferOperations.List("transferOperations", string(bfilter)).Pages(ctx, visit)
 }
+
+// Run starts an immediate operation of the named transfer job.
+func (j *Job) Run(ctx context.Context, name string) (*storagetransfer.Operation, error) {
+	run := &storagetransfer.RunTransferJobRequest{ProjectId: j.project}
+	return j.service.TransferJobs.Run(name, run).Context(ctx).Do()
+}
+
+// Operation retrieves the named transfer operation.
+func (j *Job) Operation(ctx context.Context, name string) (*storagetransfer.Operation, error) {
+	return j.service.TransferOperations.Get(name).Context(ctx).Do()
+}
+
+// Pause pauses the named running transfer operation.
+func (j *Job) Pause(ctx context.Context, name string) error {
+	_, err := j.service.TransferOperations.Pause(name, &storagetransfer.PauseTransferOperationRequest{}).Context(ctx).Do()
+	return err
+}
+
+// Resume resumes the named paused transfer operation.
+func (j *Job) Resume(ctx context.Context, name string) error {
+	_, err := j.service.TransferOperations.Resume(name, &storagetransfer.ResumeTransferOperationRequest{}).Context(ctx).Do()
+	return err
+}
+
+// Cancel cancels the named transfer operation.
+func (j *Job) Cancel(ctx context.Context, name string) error {
+	_, err := j.service.TransferOperations.Cancel(name, &storagetransfer.CancelOperationRequest{}).Context(ctx).Do()
+	return err
+}
@@ -0,0 +1,41 @@
+// Copyright © 2019 gcp-config Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transfer
+
+import (
+	"bytes"
+	"context"
+
+	"google.golang.org/api/storage/v1"
+)
+
+// Objects wraps the storage API to write small objects, e.g. transfer manifests.
+type Objects struct {
+	service *storage.Service
+}
+
+// NewObjects creates a new Objects.
+func NewObjects(service *storage.Service) *Objects {
+	return &Objects{
+		service: service,
+	}
+}
+
+// Write creates or replaces the named object in bucket with content.
+func (o *Objects) Write(ctx context.Context, bucket, name string, content []byte) error {
+	obj := &storage.Object{Name: name}
+	_, err := o.service.Objects.Insert(bucket, obj).Media(bytes.NewReader(content)).Context(ctx).Do()
+	return err
+}